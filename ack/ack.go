@@ -0,0 +1,58 @@
+// Package ack tracks 👀/✅ reactions on DeployBot's own alert posts, so a
+// human noticing (or resolving) an alert in Mattermost - rather than typing
+// a command - can still suppress further escalation for it. It's the
+// reaction-based counterpart to feedback (👍/👎 noise rating) and
+// resolution (the `!resolve` command's structured close-out): same
+// postID-keyed tracking shape, different emoji and different purpose.
+package ack
+
+import (
+	"DeployBot/lru"
+)
+
+// maxTracked bounds how many posts' acknowledgment state is remembered, so
+// a long-running process doesn't grow memory without bound; the oldest
+// untouched entry is simply evicted.
+const maxTracked = 4096
+
+// state is how far an alert post has been acknowledged.
+type state int
+
+const (
+	// acknowledged means someone has seen it (👀) but not yet resolved it.
+	acknowledged state = iota
+	// resolved means someone has marked it done (✅).
+	resolved
+)
+
+var byPost = lru.New(maxTracked)
+
+// Add records a 👀/✅ reaction on postID. Any other emoji is ignored.
+func Add(postID, emojiName string) {
+	switch emojiName {
+	case "eyes":
+		byPost.Set(postID, acknowledged)
+	case "white_check_mark", "heavy_check_mark":
+		byPost.Set(postID, resolved)
+	}
+}
+
+// Acknowledged reports whether postID has a 👀 or ✅ reaction recorded -
+// either one is reason enough to suppress a follow-up escalation, since
+// someone has already seen it.
+func Acknowledged(postID string) bool {
+	if postID == "" {
+		return false
+	}
+	_, ok := byPost.Get(postID)
+	return ok
+}
+
+// Resolved reports whether postID has specifically been marked done (✅).
+func Resolved(postID string) bool {
+	if postID == "" {
+		return false
+	}
+	v, ok := byPost.Get(postID)
+	return ok && v.(state) == resolved
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"DeployBot/authz"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// isAdmin reports whether userId is listed in config.Config.AdminUsers,
+// gating chat commands that can affect the cluster beyond reading status.
+func isAdmin(userId string) bool {
+	for _, id := range globalConfig.Snapshot().AdminUsers {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// authorized decides whether userId may run command against target,
+// preferring config.Config.OPAEndpoint when one is configured so complex
+// org rules can live in a policy instead of the flat AdminUsers list. If
+// no endpoint is configured, or the policy engine can't be reached, it
+// falls back to isAdmin.
+func authorized(userId, command, target string) bool {
+	cfg := globalConfig.Snapshot()
+	if cfg.OPAEndpoint == "" {
+		return isAdmin(userId)
+	}
+
+	allowed, err := authz.Allow(cfg.OPAEndpoint, authz.Input{
+		User:    userId,
+		Command: command,
+		Target:  target,
+		Cluster: cfg.ClusterName,
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("opa authorization check failed, falling back to AdminUsers")
+		return isAdmin(userId)
+	}
+	return allowed
+}
@@ -0,0 +1,291 @@
+package adminapi
+
+import (
+	"DeployBot/approval"
+	"DeployBot/config"
+	"DeployBot/configsync"
+	"DeployBot/guard"
+	"DeployBot/metrics"
+	"DeployBot/notifier"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// CommandRunner executes a DeployBot `!` chat command under callerId's
+// identity, the same as if callerId had typed message in Mattermost -
+// subject to the same authorized()/OPA RBAC check and audit trail, just
+// reachable over HTTP for other bots/automation to call programmatically.
+// handled reports whether message matched a known command at all.
+type CommandRunner func(message, callerId, rootId string) (reply string, handled bool)
+
+// Server exposes a small authenticated HTTP API that lets operators change
+// notification filters, the target channel, and the log level at runtime,
+// persisting the change back to the config file.
+type Server struct {
+	cfg        *config.Config
+	notifiers  *notifier.Registry
+	token      string
+	gitSync    *configsync.Syncer
+	runCommand CommandRunner
+}
+
+// NewServer builds an admin API bound to cfg, requiring callers to send the
+// given bearer token. notifiers may be nil if the caller doesn't want the
+// /notifiers/reload endpoint. gitSync may be nil if the caller doesn't want
+// the /config/sync webhook endpoint. runCommand may be nil if the caller
+// doesn't want the /command bot-to-bot endpoint.
+func NewServer(cfg *config.Config, notifiers *notifier.Registry, token string, gitSync *configsync.Syncer, runCommand CommandRunner) *Server {
+	return &Server{cfg: cfg, notifiers: notifiers, token: token, gitSync: gitSync, runCommand: runCommand}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	return s.token != "" && r.Header.Get("Authorization") == "Bearer "+s.token
+}
+
+// Handler returns the http.Handler serving the admin API. Mount it wherever
+// the process already listens, or pass to http.ListenAndServe directly.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", s.handleConfig)
+	mux.HandleFunc("/notifiers/reload", s.handleNotifiersReload)
+	mux.HandleFunc("/config/sync", s.handleConfigSync)
+	mux.HandleFunc("/command", s.handleCommand)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/actions/callback", s.handleActionCallback)
+	return mux
+}
+
+// serviceIdentity resolves r's bearer token against config.Config's
+// ServiceIdentities, so a bot-to-bot caller acts under its own identity -
+// and so authorized()'s RBAC/OPA check and audit trail see who actually
+// made the request, not a blanket "admin".
+func (s *Server) serviceIdentity(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return "", false
+	}
+	identity, ok := s.cfg.Snapshot().ServiceIdentities[strings.TrimPrefix(auth, prefix)]
+	return identity, ok
+}
+
+// handleCommand lets other bots/automation invoke DeployBot's `!` commands
+// programmatically, under their own service identity rather than the admin
+// token used for the rest of this API - so RBAC and audit work exactly as
+// they would for a human typing the same command.
+func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	identity, ok := s.serviceIdentity(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if s.runCommand == nil {
+		http.Error(w, "command execution not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+		RootId  string `json:"rootId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reply, handled := s.runCommand(req.Message, identity, req.RootId)
+	if !handled {
+		http.Error(w, "unrecognized command", http.StatusBadRequest)
+		return
+	}
+	// An API caller isn't a chat user to keep quiet around, so the
+	// ephemeral/channel distinction doesn't apply here - just strip the
+	// marker rather than leaking it into the response.
+	message, _ := guard.SplitEphemeral(reply)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"reply": message})
+}
+
+// handleConfigSync triggers an immediate pull from the configured Git
+// repository, for use as a webhook target on pushes to the config repo
+// instead of waiting for the next polling interval.
+func (s *Server) handleConfigSync(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.gitSync == nil {
+		http.Error(w, "git config sync not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	before := s.cfg.Snapshot()
+	if err := s.gitSync.SyncNow(); err != nil {
+		log.Println("Config sync failed: ", err)
+		http.Error(w, "sync failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.announceDiff(before, s.cfg.Snapshot())
+	w.WriteHeader(http.StatusOK)
+}
+
+// announceDiff posts a summary of what changed between two config
+// snapshots to the "ops" notifier backend, so runtime config changes are
+// visible and auditable rather than silent.
+func (s *Server) announceDiff(before, after config.Config) {
+	changes := config.Diff(before, after)
+	if len(changes) == 0 || s.notifiers == nil {
+		return
+	}
+	ops, ok := s.notifiers.Get("ops")
+	if !ok {
+		log.Println("Config changed but no \"ops\" notifier configured to announce it:", changes)
+		return
+	}
+	message := "Config reloaded:\n- " + strings.Join(changes, "\n- ")
+	client := ops.GetClient()
+	ops.PostMessage(client, message)
+}
+
+// handleNotifiersReload validates and hot-swaps the notifier registry from
+// the current config's Notifiers list, without restarting the informers.
+func (s *Server) handleNotifiersReload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.notifiers == nil {
+		http.Error(w, "notifier registry not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	backends := s.cfg.Snapshot().Notifiers
+	if err := s.notifiers.Reload(backends); err != nil {
+		log.Println("Notifier reload failed: ", err)
+		http.Error(w, "reload failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleStats reports Kubernetes API request/throttling counters, so an
+// operator tuning KubeAPIQPS/KubeAPIBurst can see whether this instance is
+// actually getting 429'd before reaching for config.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics.Default.Snapshot())
+}
+
+// handleActionCallback is the target of the Integration.URL on interactive
+// message buttons built by mattermostapi.Action - called directly by the
+// Mattermost server, not by an operator, so it is deliberately not gated by
+// authorized(): the security boundary is the unguessable per-request token
+// stashed in the button's Context, the same trust model as guard.Resume's
+// token. approvalToken and approvalDecision are the Context keys a caller
+// must set when building the Approve/Reject Actions.
+const (
+	approvalToken    = "approval_token"
+	approvalDecision = "approval_decision"
+)
+
+func (s *Server) handleActionCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserId  string                 `json:"user_id"`
+		Context map[string]interface{} `json:"context"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, _ := req.Context[approvalToken].(string)
+	decision, _ := req.Context[approvalDecision].(string)
+	if token == "" {
+		http.Error(w, "missing approval token", http.StatusBadRequest)
+		return
+	}
+
+	result, found := approval.Resolve(token, decision == "approve")
+	if !found {
+		result = "DeployBot - that approval request is no longer available."
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"ephemeral_text": result})
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshot := s.cfg.Snapshot()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshot)
+
+	case http.MethodPost:
+		var update config.Config
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		before := s.cfg.Snapshot()
+		err := s.cfg.Update(func(c *config.Config) {
+			if update.NotificationFilters != nil {
+				c.NotificationFilters = update.NotificationFilters
+			}
+			if update.TargetChannel != "" {
+				c.TargetChannel = update.TargetChannel
+			}
+			if update.LogLevel != "" {
+				c.LogLevel = update.LogLevel
+			}
+			if update.Rules != nil {
+				c.Rules = update.Rules
+			}
+			if update.Templates != nil {
+				c.Templates = update.Templates
+			}
+		})
+		if err != nil {
+			log.Println("Failed to save config: ", err)
+			http.Error(w, "failed to save config", http.StatusInternalServerError)
+			return
+		}
+		s.announceDiff(before, s.cfg.Snapshot())
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,85 @@
+// Package approval stages a destructive or sensitive operation requested
+// via chat behind an interactive Approve/Reject button click - the
+// click-to-confirm counterpart to the confirm package's typed
+// !confirm-delete flow, for operations where a single extra click from a
+// (possibly different) human is enough friction, rather than retyping the
+// resource name.
+package approval
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// window bounds how long a staged approval request stays clickable, long
+// enough for someone else to notice and click, short enough that a stale
+// request can't fire well after it stopped being relevant.
+const window = 10 * time.Minute
+
+// maxPending bounds how many still-open approval requests are remembered,
+// so a burst of requests nobody ever clicks doesn't grow memory without
+// bound - the oldest is simply evicted.
+const maxPending = 256
+
+// Request is one operation staged behind an Approve/Reject button, for
+// Resolve to run once a human clicks.
+type Request struct {
+	// Approve runs if the button is clicked within window.
+	Approve func() string
+	// Reject runs if Reject is clicked instead; may be nil, in which case
+	// Resolve reports a generic cancellation message.
+	Reject func() string
+}
+
+type staged struct {
+	Request
+	expires time.Time
+}
+
+var (
+	mu      sync.Mutex
+	pending = make(map[string]staged)
+	order   []string
+)
+
+// Stage records req under a fresh token, for Resolve to run once a human
+// clicks Approve or Reject within window.
+func Stage(req Request) string {
+	mu.Lock()
+	defer mu.Unlock()
+	token := fmt.Sprintf("approval-%d", time.Now().UnixNano())
+	pending[token] = staged{Request: req, expires: time.Now().Add(window)}
+	order = append(order, token)
+	if len(order) > maxPending {
+		oldest := order[0]
+		order = order[1:]
+		delete(pending, oldest)
+	}
+	return token
+}
+
+// Resolve runs token's staged Approve or Reject callback, consuming the
+// request either way - a second click against the same token (a race
+// between two approvers, or a retried webhook) finds nothing and reports
+// found=false, so the underlying operation can't fire twice.
+func Resolve(token string, approve bool) (result string, found bool) {
+	mu.Lock()
+	p, ok := pending[token]
+	delete(pending, token)
+	mu.Unlock()
+
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(p.expires) {
+		return "DeployBot - that approval request expired.", true
+	}
+	if approve {
+		return p.Approve(), true
+	}
+	if p.Reject != nil {
+		return p.Reject(), true
+	}
+	return "DeployBot - cancelled.", true
+}
@@ -0,0 +1,175 @@
+// Package audit keeps a tamper-evident, append-only record of chat-driven
+// production changes on disk, for teams that need to prove after the fact
+// that nothing in the trail was edited or removed. Each line is hash
+// chained to the one before it; if DEPLOYBOT_AUDIT_KEY is set the chain is
+// HMAC-signed with it instead of plain SHA-256, so a reader without that
+// key can detect tampering but not forge a new, valid-looking chain.
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one tamper-evident audit record.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"`
+	Action   string    `json:"action"`
+	Target   string    `json:"target"`
+	Detail   string    `json:"detail"`
+	PrevHash string    `json:"prevHash"`
+	Hash     string    `json:"hash"`
+}
+
+// Writer appends Entries to a JSONL file, chaining each new entry's hash
+// off the previous one.
+type Writer struct {
+	mu       sync.Mutex
+	path     string
+	key      []byte
+	lastHash string
+}
+
+// Default is the process-wide audit trail chat commands append to. It's a
+// no-op until Open is called (so code that runs before main has configured
+// a path doesn't need to nil-check it).
+var Default = &Writer{}
+
+// Open points Default (and returns a Writer of its own, for tests or
+// alternate trails) at path, seeding the hash chain from path's last line
+// if it already exists so a restart doesn't break continuity. key is the
+// HMAC key from DEPLOYBOT_AUDIT_KEY; an empty key means the chain is
+// hashed but not signed.
+func Open(path string, key []byte) (*Writer, error) {
+	w := &Writer{path: path, key: key}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		Default = w
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("audit: couldn't parse existing entry: %v", err)
+		}
+		w.lastHash = e.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	Default = w
+	return w, nil
+}
+
+// Append adds one record to the trail, signing/hashing it off the chain's
+// current tip.
+func (w *Writer) Append(actor, action, target, detail string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.path == "" {
+		return nil
+	}
+
+	e := Entry{
+		Time:     time.Now(),
+		Actor:    actor,
+		Action:   action,
+		Target:   target,
+		Detail:   detail,
+		PrevHash: w.lastHash,
+	}
+	e.Hash = hashEntry(e, w.key)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	w.lastHash = e.Hash
+	return nil
+}
+
+// hashEntry computes e's chained hash, covering every other field plus
+// PrevHash, using HMAC-SHA256 when key is non-empty and plain SHA-256
+// otherwise.
+func hashEntry(e Entry, key []byte) string {
+	payload := fmt.Sprintf("%s|%s|%s|%s|%s|%s", e.Time.Format(time.RFC3339Nano), e.Actor, e.Action, e.Target, e.Detail, e.PrevHash)
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(payload))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify reads path's JSONL chain and recomputes every entry's hash
+// against its predecessor, returning the number of entries verified or an
+// error identifying the first one that doesn't match - a broken chain
+// means a record was edited, removed, or inserted out of order.
+func Verify(path string, key []byte) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var prevHash string
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return count, fmt.Errorf("record %d: couldn't parse: %v", count+1, err)
+		}
+		if e.PrevHash != prevHash {
+			return count, fmt.Errorf("record %d: prevHash %q doesn't match preceding record's hash %q", count+1, e.PrevHash, prevHash)
+		}
+		want := e.Hash
+		e.Hash = ""
+		got := hashEntry(e, key)
+		if got != want {
+			return count, fmt.Errorf("record %d: hash mismatch - record was tampered with or the audit key is wrong", count+1)
+		}
+		prevHash = want
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
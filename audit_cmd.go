@@ -0,0 +1,28 @@
+package main
+
+import (
+	"DeployBot/audit"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runAuditVerify implements `deploybot audit verify [path]`, checking the
+// on-disk audit trail's hash chain for tampering and exiting non-zero if
+// it's broken.
+func runAuditVerify() {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	fs.Parse(os.Args[3:])
+
+	path := "audit.jsonl"
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	count, err := audit.Verify(path, []byte(os.Getenv("DEPLOYBOT_AUDIT_KEY")))
+	if err != nil {
+		fmt.Printf("audit verify: FAILED after %d good record(s) - %v\n", count, err)
+		os.Exit(1)
+	}
+	fmt.Printf("audit verify: OK, %d record(s) verified\n", count)
+}
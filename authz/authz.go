@@ -0,0 +1,67 @@
+// Package authz delegates chat command authorization decisions to an
+// external OPA policy endpoint, so org-specific rules (who can run
+// !debug-pod against which namespace, say) don't have to be encoded as
+// Go code or a flat admin list inside DeployBot itself.
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// requestTimeout bounds how long a decision request is allowed to take
+// before the caller should fall back to its own RBAC.
+const requestTimeout = 3 * time.Second
+
+// Input is the context handed to the policy for one authorization
+// decision.
+type Input struct {
+	User    string `json:"user"`
+	Command string `json:"command"`
+	Target  string `json:"target"`
+	Cluster string `json:"cluster"`
+}
+
+type decisionRequest struct {
+	Input Input `json:"input"`
+}
+
+// decisionResponse matches OPA's Data API response shape - "result" is
+// whatever the queried rule evaluates to, normally a bool for an allow
+// rule (https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document-with-input).
+type decisionResponse struct {
+	Result bool `json:"result"`
+}
+
+// Allow queries endpoint (an OPA "data" API URL, e.g.
+// http://opa:8181/v1/data/deploybot/allow) with in and reports whether the
+// policy allowed the command. A request or decode error is returned as-is
+// rather than folded into an allow/deny, since an unreachable policy
+// engine should make the caller fall back to its own RBAC, not silently
+// grant or deny access.
+func Allow(endpoint string, in Input) (bool, error) {
+	body, err := json.Marshal(decisionRequest{Input: in})
+	if err != nil {
+		return false, err
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("opa endpoint %s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	var decision decisionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, err
+	}
+	return decision.Result, nil
+}
@@ -0,0 +1,91 @@
+package bench
+
+import (
+	"DeployBot/rules"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures a benchmark run.
+type Options struct {
+	EventsPerMinute int
+	Duration        time.Duration
+}
+
+// Report summarizes a completed benchmark run.
+type Report struct {
+	EventsSent       int64
+	EventsMatched    int64
+	Elapsed          time.Duration
+	ThroughputPerSec float64
+}
+
+// String renders the report the way `deploybot bench` prints it.
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"events sent: %d\nevents matched a rule: %d\nelapsed: %s\nthroughput: %.1f events/sec",
+		r.EventsSent, r.EventsMatched, r.Elapsed, r.ThroughputPerSec,
+	)
+}
+
+// fakeNotifier stands in for a real Mattermost backend so the benchmark
+// doesn't need a live cluster or chat server - it just counts posts.
+type fakeNotifier struct {
+	posts int64
+}
+
+func (f *fakeNotifier) Post(message string) {
+	atomic.AddInt64(&f.posts, 1)
+}
+
+// Run synthesizes opts.EventsPerMinute events for opts.Duration against a
+// fake cluster and fake Mattermost, profiling how fast the rule engine and
+// notifier can keep up.
+func Run(engine *rules.Engine, opts Options) Report {
+	if opts.Duration <= 0 {
+		opts.Duration = 10 * time.Second
+	}
+	if opts.EventsPerMinute <= 0 {
+		opts.EventsPerMinute = 6000
+	}
+
+	interval := time.Minute / time.Duration(opts.EventsPerMinute)
+	notifier := &fakeNotifier{}
+
+	var sent, matched int64
+	start := time.Now()
+	deadline := start.Add(opts.Duration)
+
+	var wg sync.WaitGroup
+	for i := 0; time.Now().Before(deadline); i++ {
+		event := rules.Event{
+			Namespace: fmt.Sprintf("ns-%d", i%10),
+			Kind:      "Deployment",
+			Name:      fmt.Sprintf("app-%d", i),
+			Reason:    "Created",
+		}
+		atomic.AddInt64(&sent, 1)
+
+		wg.Add(1)
+		go func(e rules.Event) {
+			defer wg.Done()
+			if matches := engine.Evaluate(e); len(matches) > 0 {
+				atomic.AddInt64(&matched, 1)
+				notifier.Post(e.Name)
+			}
+		}(event)
+
+		time.Sleep(interval)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	return Report{
+		EventsSent:       sent,
+		EventsMatched:    matched,
+		Elapsed:          elapsed,
+		ThroughputPerSec: float64(sent) / elapsed.Seconds(),
+	}
+}
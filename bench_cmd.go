@@ -0,0 +1,29 @@
+package main
+
+import (
+	"DeployBot/bench"
+	"DeployBot/config"
+	"DeployBot/rules"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runBench implements `deploybot bench`, profiling queue throughput against
+// a fake cluster and fake Mattermost instead of the real thing.
+func runBench() {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	rate := fs.Int("rate", 6000, "synthetic events per minute")
+	duration := fs.Duration("duration", 10e9, "how long to run the benchmark")
+	fs.Parse(os.Args[2:])
+
+	cfg, err := config.Load("config.json")
+	if err != nil {
+		fmt.Println("Couldn't load config: ", err)
+		return
+	}
+
+	engine := &rules.Engine{Rules: cfg.Snapshot().Rules}
+	report := bench.Run(engine, bench.Options{EventsPerMinute: *rate, Duration: *duration})
+	fmt.Println(report)
+}
@@ -0,0 +1,101 @@
+// Package checkpoint persists the last-seen Kubernetes resourceVersion for
+// each watched object kind to disk, so a restart knows whether it's
+// resuming after downtime instead of treating every object List returns on
+// the initial sync as brand new.
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store is the persisted last-seen resourceVersion per kind, safe for
+// concurrent use.
+type Store struct {
+	mu   sync.RWMutex
+	path string
+
+	Versions map[string]string `json:"versions"`
+	// SavedAt is when this checkpoint was last written, used on the next
+	// restart to report how long DeployBot was actually down rather than
+	// just that it was down.
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// DowntimeSince reports how long it's been since this checkpoint was last
+// saved, and whether SavedAt was ever set at all (false on a checkpoint
+// from before this field existed, or one that was never saved).
+func (s *Store) DowntimeSince() (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.SavedAt.IsZero() {
+		return 0, false
+	}
+	return time.Since(s.SavedAt), true
+}
+
+// Default is the process-wide checkpoint the controller package updates and
+// resumes from across restarts.
+var Default = &Store{Versions: map[string]string{}}
+
+// Load reads path into Default and reports whether a prior checkpoint
+// existed. A false result means this is the first time DeployBot has run
+// against this state file (or no path was configured), so callers
+// shouldn't treat an empty checkpoint as evidence of downtime.
+func Load(path string) (resumed bool, err error) {
+	Default.mu.Lock()
+	defer Default.mu.Unlock()
+	Default.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, Default); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Get returns the last-seen resourceVersion recorded for kind, or "" if
+// none has been recorded yet.
+func (s *Store) Get(kind string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Versions[kind]
+}
+
+// Set records version as the last-seen resourceVersion for kind. It does
+// not write to disk itself - callers flush periodically via Save, so a
+// high-volume event stream doesn't turn into a write syscall per event.
+func (s *Store) Set(kind, version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Versions == nil {
+		s.Versions = map[string]string{}
+	}
+	s.Versions[kind] = version
+}
+
+// Save persists the checkpoint back to the file it was loaded from. It is a
+// no-op if Load was never called with a path.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	s.SavedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	path := s.path
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return nil
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,448 @@
+package config
+
+import (
+	"DeployBot/notifier"
+	"DeployBot/retry"
+	"DeployBot/rules"
+	"DeployBot/templates"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Config holds the runtime-tunable settings for the bot. It is safe for
+// concurrent use - reads and writes go through the mutex so the admin HTTP
+// API can change things like the log level while the controller is running.
+type Config struct {
+	mu   sync.RWMutex
+	path string
+
+	// NotificationFilters lists expressions (see rules.EvalExpr for the
+	// supported ==, !=, && syntax) that a watcher's event must all satisfy
+	// before its chat notification is posted, e.g. "Namespace != dev" to
+	// keep dev-cluster noise out of chat. Severity classification, incident
+	// logging, and rule actions still run for a filtered-out event - only
+	// the chat post itself is suppressed.
+	NotificationFilters []string             `json:"notificationFilters"`
+	TargetChannel       string               `json:"targetChannel"`
+	LogLevel            string               `json:"logLevel"`
+	Rules               []rules.Rule         `json:"rules"`
+	Notifiers           []notifier.Backend   `json:"notifiers"`
+	Templates           []templates.Template `json:"templates"`
+	// Snippets maps a short name to a static bit of text templates can pull
+	// in with {{snippet "name"}}, so something repeated across several
+	// templates (a disclaimer, a support channel mention) is edited once
+	// instead of copy-pasted into each one.
+	Snippets map[string]string `json:"snippets"`
+	// LexAliasesByLocale maps a locale code (e.g. "es") to the Lex bot
+	// alias that should handle that locale's requests. Locales with no
+	// entry fall back to locale.DefaultLocale's alias.
+	LexAliasesByLocale map[string]string `json:"lexAliasesByLocale"`
+	// OfflineMode disables every integration that requires egress out of
+	// the cluster (currently: Lex) for air-gapped installs. Integrations
+	// this tree doesn't have yet (GitHub, registries) have nothing to
+	// disable; add them here if that changes.
+	OfflineMode bool `json:"offlineMode"`
+	// RetryPolicies lets each integration retry independently instead of
+	// sharing one hardcoded policy. Known keys: "kubernetes" (the
+	// controller's informer error handling), "lex", "mattermost" (posting
+	// a notification, including its retries' jitter). A missing key means
+	// retry.DefaultPolicy(). A notifier's name isn't read here; each
+	// notifier.Backend uses its own RetryPolicy.
+	RetryPolicies map[string]retry.Policy `json:"retryPolicies"`
+	// EndpointMinReady maps a Service's "namespace/name" key to the minimum
+	// ready-endpoint count it must keep before DeployBot alerts. A missing
+	// entry defaults to 1, i.e. alert once a Service has no ready endpoints
+	// left at all.
+	EndpointMinReady map[string]int `json:"endpointMinReady"`
+	// AdminUsers lists the Mattermost user IDs allowed to run commands that
+	// can affect the cluster beyond reading status (e.g. !netcheck, which
+	// creates a Pod). Everyone else gets a "restricted to admins" reply.
+	AdminUsers []string `json:"adminUsers"`
+	// OPAEndpoint, when set, is an OPA "data" API URL (e.g.
+	// http://opa:8181/v1/data/deploybot/allow) that privileged commands
+	// delegate their authorization decision to instead of AdminUsers, so
+	// org-specific rules don't need to be encoded in this config. Left
+	// empty, commands fall back to AdminUsers.
+	OPAEndpoint string `json:"opaEndpoint"`
+	// ClusterName identifies the cluster this instance of DeployBot is
+	// running against, passed to OPAEndpoint (and usable in notifications)
+	// so a single policy or channel can tell multiple clusters apart.
+	ClusterName string `json:"clusterName"`
+	// Environment labels which tier this instance runs against (e.g.
+	// "prod", "stage", "dev"). Tagged onto every notification alongside
+	// ClusterName - essential once more than one DeployBot instance posts
+	// into a shared channel, so a reader can tell which cluster/tier an
+	// alert came from without asking.
+	Environment string `json:"environment"`
+	// ResyncPeriodSeconds sets how often every controller's
+	// SharedIndexInformer does a full relist against its local cache on
+	// top of the live watch. 0 (the default) matches the original
+	// hardcoded behavior of relying entirely on the watch staying
+	// connected.
+	ResyncPeriodSeconds int `json:"resyncPeriodSeconds"`
+	// RateLimiterBaseDelayMillis, RateLimiterMaxDelaySeconds, RateLimiterQPS,
+	// and RateLimiterBurst tune the workqueue backoff controllers use when
+	// processItem keeps failing for a key, for clusters heavy enough that
+	// the default backoff either retries too aggressively or not fast
+	// enough. Zero values fall back to
+	// workqueue.DefaultControllerRateLimiter()'s own constants.
+	RateLimiterBaseDelayMillis int     `json:"rateLimiterBaseDelayMillis"`
+	RateLimiterMaxDelaySeconds int     `json:"rateLimiterMaxDelaySeconds"`
+	RateLimiterQPS             float64 `json:"rateLimiterQPS"`
+	RateLimiterBurst           int     `json:"rateLimiterBurst"`
+	// WorkerCount is how many goroutines each controller drains its
+	// workqueue with in parallel. 0 (the default) means 1, the original
+	// single-runWorker behavior; per-object-key serialization is preserved
+	// regardless of how many workers run, since the workqueue itself never
+	// hands the same key to two workers at once.
+	WorkerCount int `json:"workerCount"`
+	// LLMEnabled gates a generative (LLM-backed) responder this tree
+	// doesn't integrate yet. It's here so that integration, whenever it
+	// lands, has a config switch to check before routing anything through
+	// the guardrails package rather than inventing one at that point.
+	LLMEnabled bool `json:"llmEnabled"`
+	// EventEnrichmentEnabled turns on cloud/infrastructure context (instance
+	// type, zone, spot/on-demand) in node and OOMKilled pod notifications.
+	// Off by default since it costs an extra Node Get per enriched event.
+	EventEnrichmentEnabled bool `json:"eventEnrichmentEnabled"`
+	// MetricsServerEnabled has Deployment rollout and Pod OOMKilled
+	// notifications query metrics-server for current CPU/memory usage
+	// alongside each container's requests/limits, to help a reviewer judge
+	// whether a rollout is healthy without switching to kubectl. Off by
+	// default since it costs an extra metrics-server lookup per notification
+	// and not every cluster has metrics-server installed.
+	MetricsServerEnabled bool `json:"metricsServerEnabled"`
+	// RegistryCredentialCheckIntervalSeconds sets how often DeployBot
+	// re-validates the registry credentials in every imagePullSecrets
+	// Secret referenced by a watched ServiceAccount, via an HTTP Basic-Auth
+	// probe against each registry's /v2/ endpoint. 0 (the default) disables
+	// the check, since it makes an outbound HTTPS request per distinct
+	// registry and not every install wants that.
+	RegistryCredentialCheckIntervalSeconds int `json:"registryCredentialCheckIntervalSeconds"`
+	// EventReplayWindowMinutes has the Event controller post Warning events
+	// from its initial List that occurred within this many minutes of
+	// startup, deduped against incident.DefaultLog, so DeployBot surfaces
+	// recent problems even when it was started after they began. 0 (the
+	// default) replays nothing, the original silent-discard behavior every
+	// kind's initial List otherwise gets.
+	EventReplayWindowMinutes int `json:"eventReplayWindowMinutes"`
+	// DeleteConfirmMinAgeMinutes and DeleteConfirmMinReplicas gate the
+	// extra "type the resource name to confirm" step a delete-type command
+	// (see the confirm package) requires on top of its normal approval -
+	// crossing either threshold means the resource is old enough, or big
+	// enough, that a fat-fingered delete is expensive to undo. 0 (either
+	// default) never requires the extra step.
+	DeleteConfirmMinAgeMinutes int   `json:"deleteConfirmMinAgeMinutes"`
+	DeleteConfirmMinReplicas   int32 `json:"deleteConfirmMinReplicas"`
+	// DynamicWatches lists arbitrary CRDs to watch through the dynamic
+	// client - e.g. Argo's Rollouts or cert-manager's Certificates -
+	// without a code change for every kind an install happens to care
+	// about. See controller.DynamicWatchSpec for the fields each entry
+	// needs.
+	DynamicWatches []DynamicWatch `json:"dynamicWatches"`
+	// ServiceIdentities maps a bearer token to the identity a bot-to-bot
+	// caller authenticates as against the admin API's /command endpoint, so
+	// automation can run DeployBot's `!` commands under its own identity -
+	// subject to the same authorized() RBAC/OPA check and audit trail a
+	// human typing the command would get - instead of a blanket admin token.
+	ServiceIdentities map[string]string `json:"serviceIdentities"`
+	// FieldSelectors maps a watcher's kind ("Pod", "Event", etc. - the
+	// same string controller.Controller.kind uses) to a Kubernetes field
+	// selector applied to every List/Watch call that watcher makes, e.g.
+	// {"Pod": "status.phase!=Succeeded,status.phase!=Failed"} to cut
+	// informer memory and event volume on large clusters. A kind with no
+	// entry watches unfiltered, the original behavior.
+	FieldSelectors map[string]string `json:"fieldSelectors"`
+	// BlackoutWindows lists maintenance windows during which chat
+	// notifications are dropped (but still classified, logged to the
+	// incident timeline, and acted on by rules) so planned churn - a
+	// rolling restart, a cluster upgrade - doesn't flood the channel. See
+	// BlackoutWindow below for the field format.
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows"`
+	// CommandTimeoutSeconds bounds how long a `!` command handler may run
+	// before guard.Command stops waiting and replies with a resumable token
+	// for `!resume`, instead of leaving the channel silent
+	// while a slow API call hangs. 0 (the default) is guard's own
+	// hardcoded fallback.
+	CommandTimeoutSeconds int `json:"commandTimeoutSeconds"`
+	// KubernetesDashboardURL is the base URL of the cluster's Kubernetes
+	// Dashboard, e.g. "https://dashboard.example.com". Left empty (the
+	// default), a new-Deployment notification's rich attachment omits its
+	// title link instead of pointing at a dashboard this install doesn't
+	// have.
+	KubernetesDashboardURL string `json:"kubernetesDashboardUrl"`
+	// KubeAPIQPS and KubeAPIBurst tune the rest.Config this instance's
+	// Kubernetes clientset throttles itself to, separately from
+	// RateLimiterQPS/RateLimiterBurst (which pace workqueue retries, not
+	// raw request volume). 0 (either default) falls back to client-go's
+	// own default (QPS 5, Burst 10). Raise these on a cluster where a
+	// heavy feature (bulk ops, !whats-wrong, drift scans) is starving
+	// other controllers of API bandwidth.
+	KubeAPIQPS   float64 `json:"kubeAPIQPS"`
+	KubeAPIBurst int     `json:"kubeAPIBurst"`
+	// ChannelAutoCreate, when Enabled, has mattermostapi create a missing
+	// configured or routed channel instead of failing the post with
+	// "channel name not available" - so a new namespace or environment can
+	// get its own channel automatically instead of requiring an admin to
+	// provision it by hand first.
+	ChannelAutoCreate ChannelAutoCreate `json:"channelAutoCreate"`
+	// MattermostTLS configures the HTTPS connection to the Mattermost
+	// server (and to an incoming webhook notifier) for a server whose
+	// certificate isn't already covered by the host's trusted root CAs.
+	MattermostTLS MattermostTLS `json:"mattermostTLS"`
+	// ProxyURL, when set, is the HTTP/HTTPS proxy every outbound
+	// Mattermost and AWS Lex connection is made through - e.g.
+	// "http://proxy.example.com:3128" for a cluster that can only reach
+	// the outside world through one. Left empty (the default), the
+	// standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables are
+	// honored instead.
+	ProxyURL string `json:"proxyURL"`
+}
+
+// ChannelAutoCreate mirrors mattermostapi.ChannelAutoCreate - duplicated
+// rather than imported the same way BlackoutWindow mirrors
+// controller.blackoutSchedule, so config doesn't need to import
+// mattermostapi just for this one struct's shape.
+type ChannelAutoCreate struct {
+	Enabled bool   `json:"enabled"`
+	Prefix  string `json:"prefix"`
+	Purpose string `json:"purpose"`
+}
+
+// MattermostTLS mirrors mattermostapi.TLSConfig - duplicated rather than
+// imported the same way ChannelAutoCreate is.
+type MattermostTLS struct {
+	CACertPath         string `json:"caCertPath"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// BlackoutWindow is one operator-configured maintenance window, mirroring
+// controller.blackoutSchedule - duplicated rather than imported the same
+// way DynamicWatch is.
+type BlackoutWindow struct {
+	// Schedule is a 5-field cron expression (minute hour day-of-month
+	// month day-of-week). Each field is either "*" or a comma-separated
+	// list of literal values - no ranges or steps, the same deliberately
+	// narrow subset notificationFilters' expressions accept.
+	Schedule string `json:"schedule"`
+	// DurationMinutes is how long the window stays open once Schedule
+	// matches the current minute.
+	DurationMinutes int `json:"durationMinutes"`
+	// Reason is included in the window-closed summary post, e.g. "weekly
+	// cluster patching".
+	Reason string `json:"reason"`
+}
+
+// DynamicWatch is one operator-configured resource to watch through the
+// dynamic client, mirroring controller.DynamicWatchSpec - duplicated
+// rather than imported since controller doesn't import config (main wires
+// the two together, the same way it does for every other controller.SetX
+// call).
+type DynamicWatch struct {
+	GroupVersion string `json:"groupVersion"` // e.g. "argoproj.io/v1alpha1"
+	Resource     string `json:"resource"`     // plural, e.g. "rollouts"
+	Kind         string `json:"kind"`         // becomes the notification category
+	Namespaced   bool   `json:"namespaced"`
+}
+
+// Default returns the built-in configuration used when no config file is
+// present on disk.
+func Default() *Config {
+	return &Config{
+		NotificationFilters: []string{},
+		TargetChannel:       "DevopsBot",
+		LogLevel:            "info",
+		LexAliasesByLocale:  map[string]string{"en": "devopsbot"},
+		RetryPolicies:       map[string]retry.Policy{"kubernetes": retry.DefaultPolicy(), "lex": retry.DefaultPolicy(), "mattermost": retry.DefaultPolicy()},
+		EndpointMinReady:    map[string]int{},
+		AdminUsers:          []string{},
+	}
+}
+
+// Load reads the config from path, falling back to Default if the file does
+// not exist yet.
+func Load(path string) (*Config, error) {
+	c := Default()
+	c.path = path
+	templates.Default.Seed(templates.DefaultTemplates)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	c.path = path
+	templates.Default.Sync(c.Templates)
+	templates.Default.SyncSnippets(c.Snippets)
+	return c, nil
+}
+
+// Save persists the config back to the file it was loaded from, the same
+// way Mattermost's own SaveConfig writes its config.json.
+func (c *Config) Save() error {
+	c.mu.RLock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if c.path == "" {
+		return nil
+	}
+	return ioutil.WriteFile(c.path, data, 0644)
+}
+
+// Snapshot returns a copy of the config safe to read without holding a lock.
+func (c *Config) Snapshot() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Config{
+		NotificationFilters:                    append([]string{}, c.NotificationFilters...),
+		TargetChannel:                          c.TargetChannel,
+		LogLevel:                               c.LogLevel,
+		Rules:                                  append([]rules.Rule{}, c.Rules...),
+		Notifiers:                              append([]notifier.Backend{}, c.Notifiers...),
+		Templates:                              append([]templates.Template{}, c.Templates...),
+		Snippets:                                copyStringMap(c.Snippets),
+		LexAliasesByLocale:                     copyStringMap(c.LexAliasesByLocale),
+		OfflineMode:                            c.OfflineMode,
+		RetryPolicies:                          copyRetryPolicies(c.RetryPolicies),
+		EndpointMinReady:                       copyIntMap(c.EndpointMinReady),
+		AdminUsers:                             append([]string{}, c.AdminUsers...),
+		OPAEndpoint:                            c.OPAEndpoint,
+		ClusterName:                            c.ClusterName,
+		Environment:                            c.Environment,
+		ResyncPeriodSeconds:                    c.ResyncPeriodSeconds,
+		RateLimiterBaseDelayMillis:              c.RateLimiterBaseDelayMillis,
+		RateLimiterMaxDelaySeconds:              c.RateLimiterMaxDelaySeconds,
+		RateLimiterQPS:                          c.RateLimiterQPS,
+		RateLimiterBurst:                        c.RateLimiterBurst,
+		WorkerCount:                             c.WorkerCount,
+		LLMEnabled:                              c.LLMEnabled,
+		EventEnrichmentEnabled:                  c.EventEnrichmentEnabled,
+		MetricsServerEnabled:                    c.MetricsServerEnabled,
+		RegistryCredentialCheckIntervalSeconds:  c.RegistryCredentialCheckIntervalSeconds,
+		EventReplayWindowMinutes:                c.EventReplayWindowMinutes,
+		DeleteConfirmMinAgeMinutes:              c.DeleteConfirmMinAgeMinutes,
+		DeleteConfirmMinReplicas:                c.DeleteConfirmMinReplicas,
+		DynamicWatches:                          append([]DynamicWatch{}, c.DynamicWatches...),
+		ServiceIdentities:                       copyStringMap(c.ServiceIdentities),
+		FieldSelectors:                          copyStringMap(c.FieldSelectors),
+		BlackoutWindows:                         append([]BlackoutWindow{}, c.BlackoutWindows...),
+		CommandTimeoutSeconds:                   c.CommandTimeoutSeconds,
+		KubernetesDashboardURL:                  c.KubernetesDashboardURL,
+		KubeAPIQPS:                              c.KubeAPIQPS,
+		KubeAPIBurst:                            c.KubeAPIBurst,
+		ChannelAutoCreate:                       c.ChannelAutoCreate,
+		MattermostTLS:                           c.MattermostTLS,
+		ProxyURL:                                c.ProxyURL,
+	}
+}
+
+func copyRetryPolicies(m map[string]retry.Policy) map[string]retry.Policy {
+	out := make(map[string]retry.Policy, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func copyIntMap(m map[string]int) map[string]int {
+	out := make(map[string]int, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Update applies fn to the config under lock and persists the result.
+func (c *Config) Update(fn func(*Config)) error {
+	c.mu.Lock()
+	fn(c)
+	templates.Default.Sync(c.Templates)
+	templates.Default.SyncSnippets(c.Snippets)
+	c.mu.Unlock()
+	return c.Save()
+}
+
+// ValidateOffline fails if OfflineMode is set but some other configured
+// feature still requires egress out of the cluster. Called once at startup
+// so an air-gapped install finds out immediately, not the first time the
+// feature tries (and fails) to reach out.
+func (c *Config) ValidateOffline(gitRepoURL string) error {
+	if !c.Snapshot().OfflineMode {
+		return nil
+	}
+	if gitRepoURL != "" {
+		return fmt.Errorf("offline mode is enabled but a config git repo (%s) is configured, which requires egress", gitRepoURL)
+	}
+	return nil
+}
+
+// Diff describes what changed between two config snapshots, in a form
+// suitable for posting to the ops channel as an audit trail.
+func Diff(before, after Config) []string {
+	var changes []string
+
+	if before.TargetChannel != after.TargetChannel {
+		changes = append(changes, fmt.Sprintf("target channel rerouted from %q to %q", before.TargetChannel, after.TargetChannel))
+	}
+	if before.LogLevel != after.LogLevel {
+		changes = append(changes, fmt.Sprintf("log level changed from %q to %q", before.LogLevel, after.LogLevel))
+	}
+
+	for _, r := range after.Rules {
+		if !hasRule(before.Rules, r.Name) {
+			changes = append(changes, "rule added: "+r.Name)
+		}
+	}
+	for _, r := range before.Rules {
+		if !hasRule(after.Rules, r.Name) {
+			changes = append(changes, "rule removed: "+r.Name)
+		}
+	}
+
+	for _, t := range after.Templates {
+		if prevVersion, ok := templateVersion(before.Templates, t.Name); !ok {
+			changes = append(changes, "template added: "+t.Name+" "+t.Version)
+		} else if prevVersion != t.Version {
+			changes = append(changes, fmt.Sprintf("template %s updated: %s -> %s", t.Name, prevVersion, t.Version))
+		}
+	}
+
+	return changes
+}
+
+func templateVersion(ts []templates.Template, name string) (string, bool) {
+	for _, t := range ts {
+		if t.Name == name {
+			return t.Version, true
+		}
+	}
+	return "", false
+}
+
+func hasRule(rs []rules.Rule, name string) bool {
+	for _, r := range rs {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+// Package configmapsync reconciles DeployBot's config.Config against a
+// single Kubernetes ConfigMap's config.json key, kept up to date by an
+// informer watching just that object - so a kubectl apply to the
+// ConfigMap is enough to pick up a change, no volume mount or restart
+// required.
+package configmapsync
+
+import (
+	"DeployBot/config"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DataKey is the ConfigMap data key expected to hold a config.json
+// payload, the same shape config.Load parses from disk.
+const DataKey = "config.json"
+
+// Syncer reconciles cfg against the DataKey entry of a single ConfigMap,
+// identified by namespace/name.
+type Syncer struct {
+	cfg       *config.Config
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// New builds a Syncer watching the ConfigMap namespace/name, applying it
+// to cfg on every Add/Update once Start runs.
+func New(client kubernetes.Interface, cfg *config.Config, namespace, name string) *Syncer {
+	return &Syncer{cfg: cfg, client: client, namespace: namespace, name: name}
+}
+
+// Start runs an informer scoped to this single ConfigMap until stopCh is
+// closed, applying its current contents on Add and on every later Update.
+func (s *Syncer) Start(stopCh <-chan struct{}) {
+	selector := fields.OneTermEqualSelector("metadata.name", s.name).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return s.client.CoreV1().ConfigMaps(s.namespace).List(options)
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return s.client.CoreV1().ConfigMaps(s.namespace).Watch(options)
+		},
+	}
+
+	_, informer := cache.NewInformer(listWatch, &api_v1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.apply,
+		UpdateFunc: func(_, obj interface{}) { s.apply(obj) },
+	})
+	informer.Run(stopCh)
+}
+
+// apply reconciles cfg against cm's DataKey entry, the same curated subset
+// of fields configsync.Syncer applies from a Git-pulled config.json. A
+// ConfigMap with no DataKey, or one that fails to parse, leaves the
+// running config untouched - logged rather than fatal, since a bad
+// kubectl apply shouldn't take the bot down.
+func (s *Syncer) apply(obj interface{}) {
+	cm, ok := obj.(*api_v1.ConfigMap)
+	if !ok {
+		return
+	}
+	data, ok := cm.Data[DataKey]
+	if !ok {
+		fmt.Printf("configmapsync: ConfigMap %s/%s has no %s key, ignoring\n", s.namespace, s.name, DataKey)
+		return
+	}
+
+	tmp, err := ioutil.TempFile("", "deploybot-configmap-*.json")
+	if err != nil {
+		fmt.Println("configmapsync: ", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(data); err != nil {
+		tmp.Close()
+		fmt.Println("configmapsync: writing temp file: ", err)
+		return
+	}
+	tmp.Close()
+
+	pulled, err := config.Load(tmp.Name())
+	if err != nil {
+		fmt.Printf("configmapsync: ConfigMap %s/%s %s didn't parse, leaving config untouched: %v\n", s.namespace, s.name, DataKey, err)
+		return
+	}
+
+	snapshot := pulled.Snapshot()
+	if err := s.cfg.Update(func(c *config.Config) {
+		c.NotificationFilters = snapshot.NotificationFilters
+		c.TargetChannel = snapshot.TargetChannel
+		c.LogLevel = snapshot.LogLevel
+		c.Rules = snapshot.Rules
+		c.Notifiers = snapshot.Notifiers
+		c.Templates = snapshot.Templates
+	}); err != nil {
+		fmt.Printf("configmapsync: applying ConfigMap %s/%s: %v\n", s.namespace, s.name, err)
+	}
+}
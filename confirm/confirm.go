@@ -0,0 +1,88 @@
+// Package confirm stages a delete-type chat command behind a typed
+// confirmation - the caller must retype the target resource's name -
+// once it crosses an age or replica-count threshold, on top of whatever
+// other approval that command already requires. A lighter approval (e.g.
+// a button click) is fine for a resource that's young and small; one
+// that's old or has many replicas is expensive enough to lose that it's
+// worth making a fat-fingered delete harder to trigger by accident.
+package confirm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// window bounds how long a staged delete stays pending - long enough to
+// read the resource name back and retype it, short enough that a stale
+// confirmation can't fire well after the caller moved on.
+const window = 2 * time.Minute
+
+// Request describes one resource a delete-type command is about to act
+// on, enough to decide whether Required trips.
+type Request struct {
+	// Name is what the caller must retype verbatim to confirm.
+	Name string
+	// Age is how long the resource has existed.
+	Age time.Duration
+	// Replicas is the resource's current replica count, or 0 for
+	// resources without one (e.g. a single Pod).
+	Replicas int32
+}
+
+// Required reports whether req crosses minAge or minReplicas and so needs
+// a typed confirmation before the delete runs. Either threshold being <= 0
+// disables that check.
+func Required(req Request, minAge time.Duration, minReplicas int32) bool {
+	return (minAge > 0 && req.Age >= minAge) || (minReplicas > 0 && req.Replicas >= minReplicas)
+}
+
+// pending is one user's staged delete, waiting on Confirm to supply the
+// matching resource name before apply runs.
+type pending struct {
+	Name    string
+	Apply   func() string
+	Expires time.Time
+}
+
+var (
+	mu     sync.Mutex
+	staged = make(map[string]pending)
+)
+
+// Stage records apply to run once userId retypes name via Confirm, within
+// Window(). A second Stage for the same userId before that replaces the
+// first - only the most recently staged delete can be confirmed.
+func Stage(userId, name string, apply func() string) {
+	mu.Lock()
+	defer mu.Unlock()
+	staged[userId] = pending{Name: name, Apply: apply, Expires: time.Now().Add(window)}
+}
+
+// Confirm runs userId's staged delete if typed matches the resource name
+// Stage recorded for them and the confirmation hasn't expired. The staged
+// delete is consumed either way - a wrong or expired confirmation doesn't
+// get a second try against the same staged command.
+func Confirm(userId, typed string) (result string, ok bool) {
+	mu.Lock()
+	p, found := staged[userId]
+	delete(staged, userId)
+	mu.Unlock()
+
+	if !found {
+		return "no pending delete to confirm; run the delete command first.", false
+	}
+	if time.Now().After(p.Expires) {
+		return "that confirmation expired; run the delete command again.", false
+	}
+	if typed != p.Name {
+		return fmt.Sprintf("typed name %q doesn't match %q; run the delete command again.", typed, p.Name), false
+	}
+	return p.Apply(), true
+}
+
+// Window returns how long a staged delete stays pending, for a command's
+// own confirmation prompt to quote back to the caller.
+func Window() time.Duration {
+	return window
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"DeployBot/confirm"
+	"DeployBot/guard"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// confirmDeleteCommand implements `!confirm-delete <name>`, completing a
+// delete-type command staged via stageDeleteConfirmation because the
+// target resource crossed globalConfig's age or replica-count threshold.
+func confirmDeleteCommand(message, userId string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 {
+		return guard.Ephemeral("Usage: !confirm-delete <name>")
+	}
+
+	result, ok := confirm.Confirm(userId, fields[1])
+	if !ok {
+		return "DeployBot - " + result
+	}
+	return result
+}
+
+// stageDeleteConfirmation is the hook a delete-type command calls once it
+// has decided (via confirm.Required, against globalConfig's
+// DeleteConfirmMinAgeMinutes/DeleteConfirmMinReplicas thresholds) that its
+// target is old or large enough to need an extra typed confirmation beyond
+// whatever lighter approval it already requires. apply is the actual
+// delete, run only once the caller retypes name via !confirm-delete within
+// confirm.Window().
+func stageDeleteConfirmation(userId, name string, apply func() string) string {
+	confirm.Stage(userId, name, apply)
+	return fmt.Sprintf("DeployBot - %s is old or large enough to need extra confirmation. Run `!confirm-delete %s` within %s to proceed.", name, name, confirm.Window())
+}
+
+// deleteConfirmThresholds reads globalConfig's configured age/replica
+// thresholds, for a delete-type command to pass to confirm.Required.
+func deleteConfirmThresholds() (minAge time.Duration, minReplicas int32) {
+	snap := globalConfig.Snapshot()
+	return time.Duration(snap.DeleteConfirmMinAgeMinutes) * time.Minute, snap.DeleteConfirmMinReplicas
+}
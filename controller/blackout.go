@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"DeployBot/config"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// blackoutSchedule is one parsed config.BlackoutWindow: a 5-field cron
+// match (nil field = wildcard, matches anything) plus how long the window
+// stays open once it matches.
+type blackoutSchedule struct {
+	minute   map[int]bool
+	hour     map[int]bool
+	dom      map[int]bool
+	month    map[int]bool
+	dow      map[int]bool
+	duration time.Duration
+	reason   string
+}
+
+// blackoutSchedules is the parsed form of SetBlackoutWindows' specs,
+// checked once a minute by runBlackoutWindows.
+var blackoutSchedules []blackoutSchedule
+
+// SetBlackoutWindows overrides blackoutSchedules, parsing each spec's
+// Schedule. A spec that fails to parse is logged and skipped rather than
+// failing Start() outright - one typo'd window shouldn't block every
+// other one from taking effect.
+func SetBlackoutWindows(specs []config.BlackoutWindow) {
+	blackoutSchedules = nil
+	for _, spec := range specs {
+		s, err := parseBlackoutSchedule(spec)
+		if err != nil {
+			fmt.Println("blackout window:", err)
+			continue
+		}
+		blackoutSchedules = append(blackoutSchedules, s)
+	}
+}
+
+// parseBlackoutSchedule parses spec.Schedule's 5 space-separated cron
+// fields (minute hour day-of-month month day-of-week). Each field is
+// either "*" or a comma-separated list of literal integers - no ranges or
+// steps.
+func parseBlackoutSchedule(spec config.BlackoutWindow) (blackoutSchedule, error) {
+	fields := strings.Fields(spec.Schedule)
+	if len(fields) != 5 {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: want 5 fields (minute hour dom month dow), got %d", spec.Schedule, len(fields))
+	}
+	minute, err := parseCronField(fields[0])
+	if err != nil {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: minute: %v", spec.Schedule, err)
+	}
+	hour, err := parseCronField(fields[1])
+	if err != nil {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: hour: %v", spec.Schedule, err)
+	}
+	dom, err := parseCronField(fields[2])
+	if err != nil {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: day-of-month: %v", spec.Schedule, err)
+	}
+	month, err := parseCronField(fields[3])
+	if err != nil {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: month: %v", spec.Schedule, err)
+	}
+	dow, err := parseCronField(fields[4])
+	if err != nil {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: day-of-week: %v", spec.Schedule, err)
+	}
+	if spec.DurationMinutes <= 0 {
+		return blackoutSchedule{}, fmt.Errorf("schedule %q: durationMinutes must be > 0", spec.Schedule)
+	}
+	return blackoutSchedule{
+		minute:   minute,
+		hour:     hour,
+		dom:      dom,
+		month:    month,
+		dow:      dow,
+		duration: time.Duration(spec.DurationMinutes) * time.Minute,
+		reason:   spec.Reason,
+	}, nil
+}
+
+// parseCronField parses one cron field: "*" (nil, matches everything) or a
+// comma-separated list of literal integers.
+func parseCronField(field string) (map[int]bool, error) {
+	if field == "*" {
+		return nil, nil
+	}
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not \"*\" or a literal integer list", field)
+		}
+		values[n] = true
+	}
+	return values, nil
+}
+
+// matchesMinute reports whether t's minute, hour, day-of-month, month, and
+// day-of-week all satisfy s.
+func (s blackoutSchedule) matchesMinute(t time.Time) bool {
+	return cronFieldMatches(s.minute, t.Minute()) &&
+		cronFieldMatches(s.hour, t.Hour()) &&
+		cronFieldMatches(s.dom, t.Day()) &&
+		cronFieldMatches(s.month, int(t.Month())) &&
+		cronFieldMatches(s.dow, int(t.Weekday()))
+}
+
+func cronFieldMatches(field map[int]bool, value int) bool {
+	return field == nil || field[value]
+}
+
+// blackoutState tracks whether a maintenance window is currently open and
+// what's been suppressed during it, so the window-closed post can
+// summarize what chat would otherwise have seen.
+type blackoutState struct {
+	mu         sync.Mutex
+	active     bool
+	until      time.Time
+	reason     string
+	suppressed map[string]int
+}
+
+var blackout = &blackoutState{}
+
+// runBlackoutWindows ticks once a minute, re-evaluating blackoutSchedules
+// against the current time: a matching schedule (re-)opens the window for
+// its duration, and the window closing (no schedule has kept it open)
+// posts a summary of what was suppressed. Runs until stopCh closes.
+func runBlackoutWindows(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			tickBlackoutWindows(time.Now())
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func tickBlackoutWindows(now time.Time) {
+	blackout.mu.Lock()
+	for _, s := range blackoutSchedules {
+		if s.matchesMinute(now) {
+			blackout.active = true
+			blackout.until = now.Add(s.duration)
+			if s.reason != "" {
+				blackout.reason = s.reason
+			}
+		}
+	}
+	closing := blackout.active && now.After(blackout.until)
+	var suppressed map[string]int
+	var reason string
+	if closing {
+		blackout.active = false
+		suppressed = blackout.suppressed
+		reason = blackout.reason
+		blackout.suppressed = nil
+		blackout.reason = ""
+	}
+	blackout.mu.Unlock()
+
+	if closing {
+		postBlackoutSummary(reason, suppressed)
+	}
+}
+
+// blackoutActive reports whether a maintenance window is currently open.
+func blackoutActive() bool {
+	blackout.mu.Lock()
+	defer blackout.mu.Unlock()
+	return blackout.active
+}
+
+// recordSuppressed tallies one notification in category ("Kind/Reason",
+// the same string notify's correlation logging uses) dropped because a
+// blackout window was open, for the eventual window-closed summary.
+func recordSuppressed(category string) {
+	blackout.mu.Lock()
+	defer blackout.mu.Unlock()
+	if blackout.suppressed == nil {
+		blackout.suppressed = map[string]int{}
+	}
+	blackout.suppressed[category]++
+}
+
+func postBlackoutSummary(reason string, suppressed map[string]int) {
+	if len(suppressed) == 0 {
+		return
+	}
+
+	message := "DeployBot - maintenance window closed"
+	if reason != "" {
+		message += " (" + reason + ")"
+	}
+	message += ". Notifications held during the window:"
+	for category, count := range suppressed {
+		message += fmt.Sprintf("\n- %s: %d", category, count)
+	}
+
+	mm := sharedMM.WithChannel("DevopsBot")
+	client, err := mm.TryGetClient()
+	if err != nil {
+		logrus.WithError(err).Warn("blackout summary: couldn't get mattermost client")
+		return
+	}
+	corrID := string(uuid.NewUUID())
+	logrus.WithField("correlation_id", corrID).Info(message)
+	if notificationTag != "" {
+		message = notificationTag + " " + message
+	}
+	mm.PostMessage(client, fmt.Sprintf("%s _(ref: %s)_", message, corrID))
+}
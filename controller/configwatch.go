@@ -0,0 +1,317 @@
+package controller
+
+import (
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// configRefTracker remembers which ConfigMaps and Secrets are referenced by
+// which watched Deployments, so the ConfigMap/Secret controllers can tell
+// drift that actually matters (something we watch depends on it) apart from
+// the general noise of unrelated cluster config changing.
+type configRefTracker struct {
+	mu         sync.Mutex
+	configMaps map[string]map[string]bool // "namespace/configmap" -> set of deployment names
+	secrets    map[string]map[string]bool // "namespace/secret" -> set of deployment names
+}
+
+// configRefs is process-wide, like incident.DefaultLog, since the Deployment
+// controller and the ConfigMap/Secret controllers are separate *Controller
+// instances that need to share this state.
+var configRefs = newConfigRefTracker()
+
+func newConfigRefTracker() *configRefTracker {
+	return &configRefTracker{
+		configMaps: make(map[string]map[string]bool),
+		secrets:    make(map[string]map[string]bool),
+	}
+}
+
+// trackDeployment records the ConfigMaps and Secrets referenced by a
+// Deployment's pod template, replacing whatever it previously recorded for
+// that deployment.
+func (t *configRefTracker) trackDeployment(namespace, deployment string, podSpec api_v1.PodSpec) {
+	configMapNames, secretNames := referencedConfig(podSpec)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	untrackDeploymentLocked(t.configMaps, namespace, deployment)
+	untrackDeploymentLocked(t.secrets, namespace, deployment)
+	for name := range configMapNames {
+		addRefLocked(t.configMaps, namespace+"/"+name, deployment)
+	}
+	for name := range secretNames {
+		addRefLocked(t.secrets, namespace+"/"+name, deployment)
+	}
+}
+
+// untrackDeployment removes every reference recorded for a deployment that
+// no longer exists.
+func (t *configRefTracker) untrackDeployment(namespace, deployment string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	untrackDeploymentLocked(t.configMaps, namespace, deployment)
+	untrackDeploymentLocked(t.secrets, namespace, deployment)
+}
+
+// referencingConfigMap returns the names of the deployments (if any) known
+// to reference the given ConfigMap, sorted for stable messages.
+func (t *configRefTracker) referencingConfigMap(namespace, name string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return sortedKeys(t.configMaps[namespace+"/"+name])
+}
+
+// referencingSecret is referencingConfigMap for Secrets.
+func (t *configRefTracker) referencingSecret(namespace, name string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return sortedKeys(t.secrets[namespace+"/"+name])
+}
+
+func addRefLocked(m map[string]map[string]bool, key, deployment string) {
+	if m[key] == nil {
+		m[key] = make(map[string]bool)
+	}
+	m[key][deployment] = true
+}
+
+func untrackDeploymentLocked(m map[string]map[string]bool, namespace, deployment string) {
+	prefix := namespace + "/"
+	for key, deployments := range m {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		delete(deployments, deployment)
+		if len(deployments) == 0 {
+			delete(m, key)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// referencedConfig collects the ConfigMap and Secret names a pod spec reads
+// from, whether via a volume, envFrom, or an individual env var's valueFrom.
+func referencedConfig(spec api_v1.PodSpec) (configMaps, secrets map[string]bool) {
+	configMaps = make(map[string]bool)
+	secrets = make(map[string]bool)
+
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			configMaps[v.ConfigMap.Name] = true
+		}
+		if v.Secret != nil {
+			secrets[v.Secret.SecretName] = true
+		}
+	}
+
+	containers := append(append([]api_v1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		for _, ef := range c.EnvFrom {
+			if ef.ConfigMapRef != nil {
+				configMaps[ef.ConfigMapRef.Name] = true
+			}
+			if ef.SecretRef != nil {
+				secrets[ef.SecretRef.Name] = true
+			}
+		}
+		for _, e := range c.Env {
+			if e.ValueFrom == nil {
+				continue
+			}
+			if e.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps[e.ValueFrom.ConfigMapKeyRef.Name] = true
+			}
+			if e.ValueFrom.SecretKeyRef != nil {
+				secrets[e.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+	}
+	return configMaps, secrets
+}
+
+func newControllerConfigMap(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps(meta_v1.NamespaceAll).List(withFieldSelector("ConfigMap", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ConfigMaps(meta_v1.NamespaceAll).Watch(withFieldSelector("ConfigMap", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.ConfigMap{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.ConfigMap{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if cm, ok := obj.(*api_v1.ConfigMap); ok && !nsFilter.Allowed(cm.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			oldCM, ok1 := old.(*api_v1.ConfigMap)
+			newCM, ok2 := new.(*api_v1.ConfigMap)
+			if ok1 && ok2 && oldCM.ResourceVersion == newCM.ResourceVersion {
+				return
+			}
+			enqueue(new)
+		},
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-configmap"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       "ConfigMap",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+	}
+}
+
+func newControllerSecret(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Secrets(meta_v1.NamespaceAll).List(withFieldSelector("Secret", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Secrets(meta_v1.NamespaceAll).Watch(withFieldSelector("Secret", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.Secret{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.Secret{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if s, ok := obj.(*api_v1.Secret); ok && !nsFilter.Allowed(s.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		// Secret creation is otherwise ignored - Secrets are sensitive and a
+		// brand new one isn't "changed" yet - but Helm stores each release
+		// revision as its own new Secret, so a Helm release secret has to be
+		// caught on creation or every upgrade would be missed entirely.
+		AddFunc: func(obj interface{}) {
+			if s, ok := obj.(*api_v1.Secret); ok && isHelmReleaseSecret(s) {
+				enqueue(obj)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			oldSecret, ok1 := old.(*api_v1.Secret)
+			newSecret, ok2 := new.(*api_v1.Secret)
+			if ok1 && ok2 && oldSecret.ResourceVersion == newSecret.ResourceVersion {
+				return
+			}
+			enqueue(new)
+		},
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-secret"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       "Secret",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		helmCharts: make(map[string]string),
+	}
+}
+
+// processConfigMapItem alerts when a ConfigMap referenced by a watched
+// Deployment changes. It never reports the ConfigMap's Data - only name,
+// namespace, and resourceVersion - since the content may be sensitive
+// configuration that shouldn't be echoed into a chat channel.
+func (c *Controller) processConfigMapItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		return nil
+	}
+	cm, ok := Obj.(*api_v1.ConfigMap)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+	deployments := configRefs.referencingConfigMap(cm.Namespace, cm.Name)
+	if len(deployments) == 0 {
+		return nil
+	}
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+	message := fmt.Sprintf("DeployBot - ConfigMap %s/%s changed (resourceVersion %s) - used by deployment(s) %s, which won't see this until their next rollout",
+		cm.Namespace, cm.Name, cm.ResourceVersion, strings.Join(deployments, ", "))
+	e := rules.Event{Namespace: cm.Namespace, Kind: "ConfigMap", Name: cm.Name, Reason: "Changed", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
+
+// processSecretItem is processConfigMapItem for Secrets.
+func (c *Controller) processSecretItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		return nil
+	}
+	secret, ok := Obj.(*api_v1.Secret)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	if isHelmReleaseSecret(secret) {
+		return c.processHelmReleaseSecret(secret)
+	}
+
+	deployments := configRefs.referencingSecret(secret.Namespace, secret.Name)
+	if len(deployments) == 0 {
+		return nil
+	}
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+	message := fmt.Sprintf("DeployBot - Secret %s/%s changed (resourceVersion %s) - used by deployment(s) %s, which won't see this until their next rollout",
+		secret.Namespace, secret.Name, secret.ResourceVersion, strings.Join(deployments, ", "))
+	e := rules.Event{Namespace: secret.Namespace, Kind: "Secret", Name: secret.Name, Reason: "Changed", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
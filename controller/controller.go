@@ -14,37 +14,423 @@ limitations under the License.
 package controller
 
 import (
+	"DeployBot/ack"
+	"DeployBot/checkpoint"
+	cfgpkg "DeployBot/config"
+	"DeployBot/configmapsync"
+	"DeployBot/feedback"
+	"DeployBot/incident"
 	"DeployBot/mattermostapi"
+	"DeployBot/metrics"
+	"DeployBot/permissions"
+	"DeployBot/retry"
+	"DeployBot/rules"
+	"DeployBot/templates"
+	"DeployBot/upgradewatch"
 	"flag"
 	"fmt"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/juju/ratelimit"
+	appsv1 "k8s.io/api/apps/v1"
 	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	"github.com/mattermost/mattermost-server/model"
 	api_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/workqueue"
 )
 
-const maxRetries = 5
+// k8sRetryPolicy governs how many times processItem is retried for a given
+// key before the controller gives up on it. Overridden from config via
+// SetRetryPolicy; defaults to the old hardcoded maxRetries = 5 behavior.
+var k8sRetryPolicy = retry.DefaultPolicy()
+
+// sharedClient is the clientset built in Start(), exposed via Client() so
+// other packages (e.g. the !owners command) can make one-off read calls
+// against the same cluster without building a second client.
+var sharedClient kubernetes.Interface
+
+// sharedConfig is the rest.Config Start() builds the clientset from,
+// exposed via RESTConfig() for callers (e.g. !debug-pod) that need it
+// directly, such as to open an exec stream.
+var sharedConfig *rest.Config
+
+// Client returns the Kubernetes clientset the running controllers use, or
+// nil if Start() hasn't been called yet.
+func Client() kubernetes.Interface {
+	return sharedClient
+}
+
+// RESTConfig returns the rest.Config the running controllers were built
+// from, or nil if Start() hasn't been called yet.
+func RESTConfig() *rest.Config {
+	return sharedConfig
+}
+
+// sharedMM is the controller package's single Mattermost identity ("veli"
+// in "devops"), created once in main and reused by every notification
+// call site below via WithChannel - which targets a different channel on
+// a per-call copy without repeating the login TryGetClient's session
+// caching now does only once. Set via SetMattermostClient before Start().
+var sharedMM *mattermostapi.MatterMost
+
+// SetMattermostClient lets main inject the controller package's shared
+// Mattermost identity, replacing the ad-hoc mattermostapi.MatterMost{...}
+// literal every notifier used to build (and log in with) for itself.
+func SetMattermostClient(mm *mattermostapi.MatterMost) {
+	sharedMM = mm
+}
+
+// configMapSource, once set, names the ConfigMap Start() watches via a
+// configmapsync.Syncer and reconciles into its cfg on every change - a
+// kubectl apply away, instead of a volume mount and restart. Left zero
+// (the default), Start() doesn't watch any ConfigMap for config.
+var configMapSource struct {
+	cfg       *cfgpkg.Config
+	namespace string
+	name      string
+}
+
+// SetConfigMapSource configures live config reconciliation from the
+// ConfigMap namespace/name's config.json key, started once Start() has
+// built its Kubernetes client.
+func SetConfigMapSource(cfg *cfgpkg.Config, namespace, name string) {
+	configMapSource.cfg = cfg
+	configMapSource.namespace = namespace
+	configMapSource.name = name
+}
+
+// permissionsReport is the startup RBAC preflight's rendered result, kept
+// around so `!permissions` can show it again without re-running every
+// SelfSubjectAccessReview call.
+var permissionsReport string
+
+// PermissionsReport returns the last startup preflight's rendered result,
+// or "" if Start() hasn't run one yet.
+func PermissionsReport() string {
+	return permissionsReport
+}
+
+// runPreflight checks every permissions.Required verb/resource against
+// client and logs the result, so missing RBAC shows up once at startup as
+// a readable report instead of as a Forbidden error the first time a
+// watcher or command needs that permission.
+func runPreflight(client kubernetes.Interface) {
+	permissionsReport = permissions.Report(permissions.Run(client))
+	fmt.Println(permissionsReport)
+}
+
+// SetRetryPolicy lets main wire in the "kubernetes" entry of
+// config.Config.RetryPolicies, so the controller's retry behavior can be
+// tuned independently of every other integration.
+func SetRetryPolicy(p retry.Policy) {
+	k8sRetryPolicy = p
+}
+
+// resyncPeriod is how often every controller's SharedIndexInformer does a
+// full relist against its local cache on top of the live watch. It
+// defaults to 0 (no periodic resync), the original hardcoded behavior of
+// relying entirely on the watch staying connected. Overridden via
+// SetResyncPeriod before Start().
+var resyncPeriod time.Duration
+
+// SetResyncPeriod overrides resyncPeriod for every controller built after
+// this call.
+func SetResyncPeriod(d time.Duration) {
+	resyncPeriod = d
+}
+
+// metricsServerEnabled gates whether Deployment/Pod notifications query
+// metrics-server (the metrics.k8s.io aggregated API) for current CPU/memory
+// usage to include alongside requests/limits. Defaults to false: a cluster
+// without metrics-server installed would otherwise fail that lookup on
+// every notification. Overridden via SetMetricsServerEnabled before
+// Start().
+var metricsServerEnabled bool
+
+// SetMetricsServerEnabled overrides metricsServerEnabled for every
+// controller built after this call.
+func SetMetricsServerEnabled(enabled bool) {
+	metricsServerEnabled = enabled
+}
+
+// fieldSelectors maps a watcher's kind (the same string set on
+// Controller.kind - "Pod", "Deployment", "Event", etc.) to a field
+// selector applied to every List/Watch call that watcher makes, e.g.
+// {"Pod": "status.phase!=Succeeded,status.phase!=Failed"} to keep
+// completed Pods out of the informer's cache and event stream entirely on
+// large clusters. A kind with no entry gets no field selector, matching
+// the original unfiltered behavior. Overridden via SetFieldSelectors
+// before Start().
+var fieldSelectors map[string]string
+
+// SetFieldSelectors overrides fieldSelectors for every controller built
+// after this call.
+func SetFieldSelectors(selectors map[string]string) {
+	fieldSelectors = selectors
+}
+
+// withFieldSelector returns options with its FieldSelector set from
+// fieldSelectors[kind], leaving options untouched if kind has no
+// configured selector.
+func withFieldSelector(kind string, options meta_v1.ListOptions) meta_v1.ListOptions {
+	if selector, ok := fieldSelectors[kind]; ok {
+		options.FieldSelector = selector
+	}
+	return options
+}
+
+// notificationTag is prefixed onto every message notify posts, so a reader
+// can tell which cluster/environment an alert came from - essential once
+// more than one DeployBot instance posts into a shared channel. Empty (the
+// default) adds no prefix, matching the original behavior. Overridden via
+// SetNotificationTag before Start().
+//
+// This only tags the message text; it doesn't route to a different
+// channel per cluster/environment - every notifier in this package still
+// targets whichever channel it passed to sharedMM.WithChannel, so true
+// per-environment channel routing would mean threading config.TargetChannel
+// through those calls instead, which is a separate, larger change.
+var notificationTag string
+
+// SetNotificationTag overrides notificationTag for every controller's
+// notify call after this point.
+func SetNotificationTag(tag string) {
+	notificationTag = tag
+}
+
+// clusterName is included as a field in every rich Deployment attachment
+// notifyAttachment posts, so a reader can tell which cluster a Created
+// Deployment notification came from without parsing notificationTag's
+// prefix. Overridden via SetClusterName.
+var clusterName string
+
+// SetClusterName overrides clusterName for every attachment notification
+// after this point.
+func SetClusterName(name string) {
+	clusterName = name
+}
+
+// dashboardURL is the base URL of the cluster's Kubernetes Dashboard,
+// turned into a per-Deployment deep link by dashboardLink. Empty (the
+// default) omits the title link entirely. Overridden via SetDashboardURL.
+var dashboardURL string
+
+// SetDashboardURL overrides dashboardURL for every attachment notification
+// after this point.
+func SetDashboardURL(url string) {
+	dashboardURL = url
+}
+
+// dashboardLink builds a Kubernetes Dashboard deep link for namespace/name,
+// or "" if dashboardURL isn't configured.
+func dashboardLink(namespace, name string) string {
+	if dashboardURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/#!/deployment/%s/%s?namespace=%s", strings.TrimRight(dashboardURL, "/"), namespace, name, namespace)
+}
+
+// renderMessage renders templateName (see templates.DefaultTemplates) with
+// data, falling back to fallback if no such template is registered or it
+// fails to execute - so a typo'd override degrades to the old hardcoded
+// wording instead of silently dropping the notification.
+func renderMessage(templateName string, data interface{}, fallback string) string {
+	rendered, err := templates.Default.Render(templateName, data)
+	if err != nil {
+		return fallback
+	}
+	return rendered
+}
+
+// eventEnrichmentEnabled gates whether node/pod notifications get an
+// enrich.Info suffix (instance type, zone, spot/on-demand). Off by
+// default since it costs an extra Node Get per enriched event; turned on
+// via SetEventEnrichment before Start().
+var eventEnrichmentEnabled bool
+
+// SetEventEnrichment turns node/pod event enrichment on or off.
+func SetEventEnrichment(enabled bool) {
+	eventEnrichmentEnabled = enabled
+}
+
+// workerCount is how many goroutines each Controller.Run drains its
+// workqueue with in parallel. Defaults to 1, the original single-runWorker
+// behavior. Overridden via SetWorkerCount before Start().
+var workerCount = 1
+
+// SetWorkerCount overrides workerCount for every controller started after
+// this call. Values less than 1 are ignored.
+func SetWorkerCount(n int) {
+	if n > 0 {
+		workerCount = n
+	}
+}
+
+// RateLimiterConfig tunes the workqueue rate limiter every controller's
+// queue uses when processItem keeps failing for a key, plus the overall
+// retry-speed token bucket on top of it. A zero field falls back to
+// workqueue.DefaultControllerRateLimiter()'s own constant for that field.
+type RateLimiterConfig struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	QPS       float64
+	Burst     int64
+}
+
+// rateLimiterConfig is overridden via SetRateLimiterConfig before Start().
+var rateLimiterConfig RateLimiterConfig
+
+// SetRateLimiterConfig overrides the workqueue rate limiter parameters
+// every controller built after this call uses.
+func SetRateLimiterConfig(cfg RateLimiterConfig) {
+	rateLimiterConfig = cfg
+}
+
+// APIClientConfig tunes the QPS/Burst the rest.Config built in Start()
+// throttles itself to against the Kubernetes API server - separate from
+// RateLimiterConfig, which paces workqueue retries rather than raw request
+// volume. A zero field falls back to client-go's own default (QPS 5, Burst
+// 10). Tune this up on a cluster where bulk features (drift scans,
+// !whats-wrong, wide ownership lookups) are starving other controllers of
+// API bandwidth, or down on a cluster the apiserver is unusually strict
+// about.
+type APIClientConfig struct {
+	QPS   float64
+	Burst int
+}
+
+// apiClientConfig is overridden via SetAPIClientConfig before Start().
+var apiClientConfig APIClientConfig
+
+// SetAPIClientConfig overrides the Kubernetes API client's QPS/Burst for
+// the rest.Config Start() builds its clientset from.
+func SetAPIClientConfig(cfg APIClientConfig) {
+	apiClientConfig = cfg
+}
+
+// newRateLimiter builds a workqueue rate limiter from rateLimiterConfig,
+// the same shape as workqueue.DefaultControllerRateLimiter() but with each
+// parameter overridable, falling back to that function's own constants
+// for whichever fields were left at zero.
+func newRateLimiter() workqueue.RateLimiter {
+	baseDelay, maxDelay := 5*time.Millisecond, 1000*time.Second
+	qps, burst := float64(10), int64(100)
+
+	if rateLimiterConfig.BaseDelay > 0 {
+		baseDelay = rateLimiterConfig.BaseDelay
+	}
+	if rateLimiterConfig.MaxDelay > 0 {
+		maxDelay = rateLimiterConfig.MaxDelay
+	}
+	if rateLimiterConfig.QPS > 0 {
+		qps = rateLimiterConfig.QPS
+	}
+	if rateLimiterConfig.Burst > 0 {
+		burst = rateLimiterConfig.Burst
+	}
+
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Bucket: ratelimit.NewBucketWithRate(qps, burst)},
+	)
+}
+
+const (
+	annotationNotify    = "deploybot.io/notify"
+	annotationChannel   = "deploybot.io/channel"
+	annotationDependsOn = "deploybot.io/depends-on"
+)
+
+// NamespaceFilter decides whether events from a namespace should be
+// watched. An empty Include list means "all namespaces except Exclude".
+type NamespaceFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Allowed reports whether ns should be processed under this filter.
+func (f NamespaceFilter) Allowed(ns string) bool {
+	for _, excluded := range f.Exclude {
+		if excluded == ns {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, included := range f.Include {
+		if included == ns {
+			return true
+		}
+	}
+	return false
+}
 
 // Controller object
 type Controller struct {
-	logger    *logrus.Entry
-	clientset kubernetes.Interface
-	queue     workqueue.RateLimitingInterface
-	informer  cache.SharedIndexInformer
+	logger            *logrus.Entry
+	clientset         kubernetes.Interface
+	queue             workqueue.RateLimitingInterface
+	informer          cache.SharedIndexInformer
+	rules             *rules.Engine
+	dispatcher        *rules.Dispatcher
+	nsFilter          NamespaceFilter
+	kind              string
+	latency           *metrics.LatencyTracker
+	nodeHealth        *nodeHealth
+	pvcAlerted        map[string]bool
+	pvWasBoundAtDel   map[string]bool
+	eventRate         *eventRateLimiter
+	deployDiffs       map[string]string
+	rolloutStarts     map[string]time.Time
+	endpointAlerted   map[string]bool
+	oomEvents         map[string][]oomEvent
+	scaleEvents       map[string][2]int32
+	genericDynamic    bool
+	pdbBlockedSince   map[string]time.Time
+	pdbAlerted        map[string]bool
+	helmCharts        map[string]string
+	deployThreads     *deployThreads
+	pullSecretAlerted map[string]bool
+	rolloutPosts      map[string]string
+	crashLoopEvents   map[string][]crashLoopEvent
+}
+
+// Manager runs several Controllers concurrently, each watching its own
+// resource type but sharing nothing else, so adding a new watched resource
+// (Pods, StatefulSets, ...) doesn't mean restarting the others.
+type Manager struct {
+	controllers []*Controller
+}
+
+// NewManager builds a Manager over the given controllers.
+func NewManager(controllers ...*Controller) *Manager {
+	return &Manager{controllers: controllers}
+}
+
+// Run starts every managed controller and blocks until stopCh is closed.
+func (m *Manager) Run(stopCh <-chan struct{}) {
+	for _, c := range m.controllers {
+		go c.Run(stopCh)
+	}
+	<-stopCh
 }
 
 func Start() {
@@ -57,42 +443,289 @@ func Start() {
 	flag.Parse()
 
 	config, _ := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if apiClientConfig.QPS > 0 {
+		config.QPS = float32(apiClientConfig.QPS)
+	}
+	if apiClientConfig.Burst > 0 {
+		config.Burst = apiClientConfig.Burst
+	}
+	metrics.RegisterDefault()
 	kubeClient, _ := kubernetes.NewForConfig(config)
+	sharedClient = kubeClient
+	sharedConfig = config
+	sharedFactory = informers.NewSharedInformerFactory(kubeClient, resyncPeriod)
+	runPreflight(kubeClient)
+
+	statePath := os.Getenv("DEPLOYBOT_STATE_FILE")
+	if statePath == "" {
+		statePath = "deploybot-state.json"
+	}
+	resumed, err := checkpoint.Load(statePath)
+	if err != nil {
+		fmt.Println("checkpoint: failed to load", statePath, ":", err)
+	}
+
+	nsFilter := NamespaceFilter{
+		Include: splitNonEmpty(os.Getenv("DEPLOYBOT_NAMESPACE_INCLUDE")),
+		Exclude: splitNonEmpty(os.Getenv("DEPLOYBOT_NAMESPACE_EXCLUDE")),
+	}
+
+	deployController := newControllerDeploymemnt(kubeClient, nsFilter)
+	podController := newControllerPod(kubeClient)
+	statefulSetController := newControllerStatefulSet(kubeClient, nsFilter)
+	daemonSetController := newControllerDaemonSet(kubeClient, nsFilter)
+	jobController := newControllerJob(kubeClient, nsFilter)
+	serviceController := newControllerService(kubeClient, nsFilter)
+	ingressController := newControllerIngress(kubeClient, nsFilter)
+	nodeController := newControllerNode(kubeClient)
+	pvcController := newControllerPVC(kubeClient, nsFilter)
+	pvController := newControllerPV(kubeClient)
+	configMapController := newControllerConfigMap(kubeClient, nsFilter)
+	secretController := newControllerSecret(kubeClient, nsFilter)
+	eventController := newControllerEvent(kubeClient, nsFilter)
+	endpointsController := newControllerEndpoints(kubeClient, nsFilter)
+	pdbController := newControllerPDB(kubeClient, nsFilter)
+	serviceAccountController := newControllerServiceAccount(kubeClient, nsFilter)
+
+	dynamicControllers := []*Controller{}
+	for _, newOCP := range []func(*rest.Config, NamespaceFilter) (*Controller, error){newControllerDeploymentConfig, newControllerRoute, newControllerBuildConfig, newControllerRollout} {
+		c, err := newOCP(config, nsFilter)
+		if err != nil {
+			fmt.Println("openshift:", err)
+			continue
+		}
+		dynamicControllers = append(dynamicControllers, c)
+	}
+
+	for _, spec := range dynamicWatches {
+		c, err := newControllerDynamicWatch(config, spec, nsFilter)
+		if err != nil {
+			fmt.Println("dynamic watch:", err)
+			continue
+		}
+		dynamicControllers = append(dynamicControllers, c)
+	}
+
+	controllers := []*Controller{deployController, podController, statefulSetController, daemonSetController, jobController, serviceController, ingressController, nodeController, pvcController, pvController, configMapController, secretController, eventController, endpointsController, pdbController, serviceAccountController}
+	controllers = append(controllers, dynamicControllers...)
+	manager := NewManager(controllers...)
+	runningManager = manager
+
+	since, haveSince := checkpoint.Default.DowntimeSince()
+	beginDowntimeDigest(len(manager.controllers), since, haveSince && resumed)
 
-	c := newControllerDeploymemnt(kubeClient)
 	stopCh := make(chan struct{})
-	defer close(stopCh)
+	shutdownCh = stopCh
+
+	// Leader election is opt-in: most installs run a single replica and
+	// don't grant DeployBot RBAC to manage ConfigMaps, so without
+	// DEPLOYBOT_LEADER_NAMESPACE set this replica just is the leader.
+	if leaderNamespace := os.Getenv("DEPLOYBOT_LEADER_NAMESPACE"); leaderNamespace != "" {
+		identity := os.Getenv("HOSTNAME")
+		if identity == "" {
+			identity = fmt.Sprintf("deploybot-%d", os.Getpid())
+		}
+		go runLeaderElection(kubeClient, leaderNamespace, identity, stopCh)
+	} else {
+		setLeading(true)
+	}
+
+	go manager.Run(stopCh)
+	go runRegistryCredentialChecks(serviceAccountController, stopCh)
+	go runBlackoutWindows(stopCh)
+	if configMapSource.cfg != nil && configMapSource.namespace != "" && configMapSource.name != "" {
+		go configmapsync.New(kubeClient, configMapSource.cfg, configMapSource.namespace, configMapSource.name).Start(stopCh)
+	}
 
-	go c.Run(stopCh)
+	checkpointTicker := time.NewTicker(30 * time.Second)
+	defer checkpointTicker.Stop()
+	go func() {
+		for {
+			select {
+			case <-checkpointTicker.C:
+				if err := checkpoint.Default.Save(); err != nil {
+					fmt.Println("checkpoint: failed to save", statePath, ":", err)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
 
-	sigterm := make(chan os.Signal, 1)
-	signal.Notify(sigterm, syscall.SIGTERM)
-	signal.Notify(sigterm, syscall.SIGINT)
-	<-sigterm
+	// Signal handling lives in main, which also owns the Mattermost
+	// connection and needs to post a final message before the process
+	// exits; Stop is how it tells this package to shut down. Block here
+	// until that happens (or the process is killed outright).
+	<-stopCh
 
+	if err := checkpoint.Default.Save(); err != nil {
+		fmt.Println("checkpoint: failed to save", statePath, ":", err)
+	}
 }
 
-func newControllerDeploymemnt(client kubernetes.Interface) *Controller {
+// runningManager and shutdownCh track the single Manager and stop channel
+// Start builds, so Stop (called from outside this package) has something to
+// shut down. Both are nil until Start has run.
+var (
+	runningManager *Manager
+	shutdownCh     chan struct{}
+)
 
-	DeployQueue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	DeployInformer := cache.NewSharedIndexInformer(
+// Stop begins a graceful shutdown of every controller started by Start: it
+// closes the shared stop channel, which stops every informer and lets each
+// worker finish its current item and exit, then waits up to drainTimeout
+// for every controller's workqueue to empty before returning. It's safe to
+// call even if drainTimeout elapses first - whatever's left in the queues
+// is simply picked back up from the informers' relist on the next restart.
+func Stop(drainTimeout time.Duration) {
+	if shutdownCh == nil {
+		return
+	}
+	close(shutdownCh)
 
-		&cache.ListWatch{
+	deadline := time.Now().Add(drainTimeout)
+	for runningManager != nil && time.Now().Before(deadline) {
+		if totalQueueLen(runningManager) == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
 
+func totalQueueLen(m *Manager) int {
+	total := 0
+	for _, c := range m.controllers {
+		total += c.queue.Len()
+	}
+	return total
+}
+
+// deploymentMeta reads the fields we care about off either an apps/v1 or an
+// apps/v1beta1 Deployment, so the rest of the controller doesn't need to
+// care which API version the cluster served.
+func deploymentMeta(obj interface{}) (namespace, name string, annotations map[string]string, created time.Time, ok bool) {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		return d.Namespace, d.Name, d.Annotations, d.CreationTimestamp.Time, true
+	case *appsv1beta1.Deployment:
+		return d.Namespace, d.Name, d.Annotations, d.CreationTimestamp.Time, true
+	default:
+		return "", "", nil, time.Time{}, false
+	}
+}
+
+// deploymentPodSpec returns the pod template spec of a watched Deployment,
+// so its ConfigMap/Secret references can be tracked for drift detection.
+func deploymentPodSpec(obj interface{}) (api_v1.PodSpec, bool) {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		return d.Spec.Template.Spec, true
+	case *appsv1beta1.Deployment:
+		return d.Spec.Template.Spec, true
+	default:
+		return api_v1.PodSpec{}, false
+	}
+}
+
+// deploymentReplicaCount returns the desired replica count of a watched
+// Deployment, for a rich notification's "Replicas" field. An unset
+// Spec.Replicas (nil, meaning "API server default of 1") reports 0 rather
+// than guessing at the default.
+func deploymentReplicaCount(obj interface{}) (int32, bool) {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		if d.Spec.Replicas != nil {
+			return *d.Spec.Replicas, true
+		}
+		return 0, true
+	case *appsv1beta1.Deployment:
+		if d.Spec.Replicas != nil {
+			return *d.Spec.Replicas, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// deploymentUID returns obj's UID, for keying an in-progress rollout's
+// edit-in-place progress post (rolloutPosts) by object identity rather than
+// namespace/name - so a Deployment deleted and recreated with the same name
+// doesn't inherit a stale post ID from the one it replaced.
+func deploymentUID(obj interface{}) (string, bool) {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		return string(d.UID), true
+	case *appsv1beta1.Deployment:
+		return string(d.UID), true
+	default:
+		return "", false
+	}
+}
+
+// supportsAppsV1 reports whether the cluster's API server serves the
+// apps/v1 group, meaning we should use it for Deployments rather than
+// apps/v1beta1. Clusters old enough to lack apps/v1 entirely fall back to
+// apps/v1beta1 so the bot keeps working there too.
+func supportsAppsV1(client kubernetes.Interface) bool {
+	_, err := client.Discovery().ServerResourcesForGroupVersion("apps/v1")
+	return err == nil
+}
+
+func newControllerDeploymemnt(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+
+	DeployQueue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	var listWatch *cache.ListWatch
+	var exampleObject runtime.Object
+
+	if supportsAppsV1(client) {
+		listWatch = &cache.ListWatch{
 			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
-				return client.AppsV1beta1().Deployments(meta_v1.NamespaceAll).List(options)
+				return client.AppsV1().Deployments(meta_v1.NamespaceAll).List(withFieldSelector("Deployment", options))
 			},
 			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
-				return client.AppsV1beta1().Deployments(meta_v1.NamespaceAll).Watch(options)
+				return client.AppsV1().Deployments(meta_v1.NamespaceAll).Watch(withFieldSelector("Deployment", options))
 			},
-		},
-		&appsv1beta1.Deployment{},
-		0,
-		cache.Indexers{},
-	)
+		}
+		exampleObject = &appsv1.Deployment{}
+	} else {
+		listWatch = &cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1beta1().Deployments(meta_v1.NamespaceAll).List(withFieldSelector("Deployment", options))
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1beta1().Deployments(meta_v1.NamespaceAll).Watch(withFieldSelector("Deployment", options))
+			},
+		}
+		exampleObject = &appsv1beta1.Deployment{}
+	}
+
+	DeployInformer := newSharedIndexInformer(exampleObject, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, exampleObject, resync, cache.Indexers{})
+	})
+
+	c := &Controller{
+		logger:        logrus.WithField("pkg", "kubewatch-deployment"),
+		clientset:     client,
+		informer:      DeployInformer,
+		queue:         DeployQueue,
+		rules:         &rules.Engine{},
+		dispatcher:    rules.NewDispatcher(),
+		nsFilter:      nsFilter,
+		kind:          "Deployment",
+		latency:       metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		deployDiffs:   make(map[string]string),
+		rolloutStarts: make(map[string]time.Time),
+		scaleEvents:   make(map[string][2]int32),
+		rolloutPosts:  make(map[string]string),
+	}
 
 	DeployInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
+			if namespace, _, _, _, ok := deploymentMeta(obj); ok && !nsFilter.Allowed(namespace) {
+				return
+			}
 			key, err := cache.MetaNamespaceKeyFunc(obj)
 			if err == nil {
 				DeployQueue.Add(key)
@@ -100,37 +733,162 @@ func newControllerDeploymemnt(client kubernetes.Interface) *Controller {
 		},
 
 		DeleteFunc: func(obj interface{}) {
+			if namespace, _, _, _, ok := deploymentMeta(obj); ok && !nsFilter.Allowed(namespace) {
+				return
+			}
 			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			if err == nil {
 				DeployQueue.Add(key)
 			}
 		},
+
+		UpdateFunc: func(old, new interface{}) {
+			if namespace, _, _, _, ok := deploymentMeta(new); ok && !nsFilter.Allowed(namespace) {
+				return
+			}
+			oldSpec, ok1 := deploymentPodSpec(old)
+			newSpec, ok2 := deploymentPodSpec(new)
+			if !ok1 || !ok2 {
+				return
+			}
+			key, err := cache.MetaNamespaceKeyFunc(new)
+			if err != nil {
+				return
+			}
+
+			oldReplicas, newReplicas := deploymentReplicas(old), deploymentReplicas(new)
+			diffs := diffDeploymentSpec(oldSpec, newSpec, oldReplicas, newReplicas)
+			if len(diffs) > 0 {
+				c.deployDiffs[key] = strings.Join(diffs, "; ")
+				if scaledOnly(diffs) {
+					c.scaleEvents[key] = [2]int32{int32Value(oldReplicas), int32Value(newReplicas)}
+				}
+				c.rolloutStarts[key] = time.Now()
+				DeployQueue.Add(key)
+				return
+			}
+
+			// No spec change - but if a rollout we started is still being
+			// tracked, this is a status-only update (replicas progressing,
+			// conditions changing) that might be the one reporting it's
+			// done, so it still needs processing.
+			if _, pending := c.rolloutStarts[key]; pending {
+				DeployQueue.Add(key)
+			}
+		},
+	})
+
+	return c
+}
+
+// newControllerWorkload builds a Controller for any apps/v1 workload kind
+// that exposes the same List/Watch shape as Deployments - StatefulSets and
+// DaemonSets today.
+func newControllerWorkload(client kubernetes.Interface, nsFilter NamespaceFilter, kind string, listWatch *cache.ListWatch, exampleObject runtime.Object) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	informer := newSharedIndexInformer(exampleObject, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, exampleObject, resync, cache.Indexers{})
+	})
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if namespace, _, _, ok := workloadMeta(obj); ok && !nsFilter.Allowed(namespace) {
+				return
+			}
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if namespace, _, _, ok := workloadMeta(obj); ok && !nsFilter.Allowed(namespace) {
+				return
+			}
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
 	})
 
 	return &Controller{
-		logger:    logrus.WithField("pkg", "kubewatch-pod"),
-		clientset: client,
-		informer:  DeployInformer,
-		queue:     DeployQueue,
+		logger:     logrus.WithField("pkg", "kubewatch-"+strings.ToLower(kind)),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       kind,
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+	}
+}
+
+func newControllerStatefulSet(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().StatefulSets(meta_v1.NamespaceAll).List(withFieldSelector("StatefulSet", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().StatefulSets(meta_v1.NamespaceAll).Watch(withFieldSelector("StatefulSet", options))
+		},
+	}
+	return newControllerWorkload(client, nsFilter, "StatefulSet", listWatch, &appsv1.StatefulSet{})
+}
+
+func newControllerDaemonSet(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.AppsV1().DaemonSets(meta_v1.NamespaceAll).List(withFieldSelector("DaemonSet", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().DaemonSets(meta_v1.NamespaceAll).Watch(withFieldSelector("DaemonSet", options))
+		},
+	}
+	return newControllerWorkload(client, nsFilter, "DaemonSet", listWatch, &appsv1.DaemonSet{})
+}
+
+// workloadMeta reads the fields we care about off a StatefulSet or
+// DaemonSet, the same way deploymentMeta does for Deployments.
+func workloadMeta(obj interface{}) (namespace, name string, created time.Time, ok bool) {
+	switch w := obj.(type) {
+	case *appsv1.StatefulSet:
+		return w.Namespace, w.Name, w.CreationTimestamp.Time, true
+	case *appsv1.DaemonSet:
+		return w.Namespace, w.Name, w.CreationTimestamp.Time, true
+	default:
+		return "", "", time.Time{}, false
 	}
 }
 
 func newControllerPod(client kubernetes.Interface) *Controller {
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
 
-	informer := cache.NewSharedIndexInformer(
-		&cache.ListWatch{
-			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
-				return client.CoreV1().Pods(meta_v1.NamespaceAll).List(options)
-			},
-			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
-				return client.CoreV1().Pods(meta_v1.NamespaceAll).Watch(options)
-			},
+	podListWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Pods(meta_v1.NamespaceAll).List(withFieldSelector("Pod", options))
 		},
-		&api_v1.Pod{},
-		0, //Skip resync
-		cache.Indexers{},
-	)
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods(meta_v1.NamespaceAll).Watch(withFieldSelector("Pod", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.Pod{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(podListWatch, &api_v1.Pod{}, resync, cache.Indexers{})
+	})
+
+	c := &Controller{
+		logger:          logrus.WithField("pkg", "kubewatch-pod"),
+		clientset:       client,
+		informer:        informer,
+		queue:           queue,
+		rules:           &rules.Engine{},
+		dispatcher:      rules.NewDispatcher(),
+		kind:            "Pod",
+		latency:         metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		oomEvents:       make(map[string][]oomEvent),
+		crashLoopEvents: make(map[string][]crashLoopEvent),
+	}
 
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -146,14 +904,32 @@ func newControllerPod(client kubernetes.Interface) *Controller {
 				queue.Add(key)
 			}
 		},
+
+		UpdateFunc: func(old, new interface{}) {
+			oldPod, ok1 := old.(*api_v1.Pod)
+			newPod, ok2 := new.(*api_v1.Pod)
+			if !ok1 || !ok2 {
+				return
+			}
+			if oldPod.Status.Phase != newPod.Status.Phase {
+				streamPodTransition(oldPod, newPod)
+			}
+			oomed := newOOMKills(client, oldPod, newPod)
+			crashed := newCrashLoops(oldPod, newPod)
+			if len(oomed) == 0 && len(crashed) == 0 {
+				return
+			}
+			key, err := cache.MetaNamespaceKeyFunc(new)
+			if err != nil {
+				return
+			}
+			c.oomEvents[key] = append(c.oomEvents[key], oomed...)
+			c.crashLoopEvents[key] = append(c.crashLoopEvents[key], crashed...)
+			queue.Add(key)
+		},
 	})
 
-	return &Controller{
-		logger:    logrus.WithField("pkg", "kubewatch-pod"),
-		clientset: client,
-		informer:  informer,
-		queue:     queue,
-	}
+	return c
 }
 
 // Run starts the kubewatch controller
@@ -170,9 +946,84 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 		return
 	}
 
+	// The initial List that WaitForCacheSync waited on fires AddFunc for
+	// every object that already existed before this bot started, which
+	// would otherwise flood the channel with a "Created" message per
+	// object on every restart. Drain whatever those initial Adds queued
+	// before starting a worker, so only genuinely new changes from here
+	// on get processed. This does mean side effects that piggyback on
+	// processing an Add (e.g. the Deployment controller's ConfigMap/Secret
+	// reference tracking) don't see pre-existing objects until they're
+	// next updated.
+	c.drainInitialQueue()
+
+	if c.kind == "Event" && eventReplayWindow > 0 {
+		c.replayRecentWarningEvents()
+	}
+
 	c.logger.Info("Kubewatch controller synced and ready")
 
-	wait.Until(c.runWorker, time.Second, stopCh)
+	// The workqueue already serializes per-key: Get() moves a key into an
+	// in-flight set until Done() is called, so re-adding a key that's
+	// currently being processed just marks it dirty for redelivery rather
+	// than handing it to a second worker. That means running workerCount
+	// workers here is safe without any extra locking - a burst of events
+	// on a large cluster gets drained in parallel without keys ever racing
+	// each other.
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.Until(c.runWorker, time.Second, stopCh)
+		}()
+	}
+	wg.Wait()
+}
+
+// drainInitialQueue discards whatever the initial informer List already
+// queued, without processing it - except that, if checkpoint.Default holds
+// a last-seen resourceVersion for this kind from a previous run, objects
+// newer than it changed while DeployBot was down. Those are counted and
+// summarized in a single digest message instead of being silently lost.
+func (c *Controller) drainInitialQueue() {
+	lastSeen := checkpoint.Default.Get(c.kind)
+	var missed int
+
+	for c.queue.Len() > 0 {
+		key, quit := c.queue.Get()
+		if quit {
+			return
+		}
+		if lastSeen != "" {
+			if obj, exists, err := c.informer.GetIndexer().GetByKey(key.(string)); err == nil && exists {
+				if metaObj, ok := obj.(meta_v1.Object); ok && resourceVersionNewer(metaObj.GetResourceVersion(), lastSeen) {
+					missed++
+				}
+			}
+		}
+		c.queue.Forget(key)
+		c.queue.Done(key)
+	}
+
+	reportDrainResult(c.kind, missed)
+}
+
+// resourceVersionNewer reports whether rv is newer than lastSeen. Both are
+// usually etcd's monotonically increasing decimal resourceVersions, which
+// compare safely as integers; if either isn't a plain integer (a mock
+// client-go's fake clientset, say), we can only tell the versions apart,
+// not order them, so any difference is treated as "newer".
+func resourceVersionNewer(rv, lastSeen string) bool {
+	if rv == "" || rv == lastSeen {
+		return false
+	}
+	rvN, err1 := strconv.ParseInt(rv, 10, 64)
+	lastN, err2 := strconv.ParseInt(lastSeen, 10, 64)
+	if err1 == nil && err2 == nil {
+		return rvN > lastN
+	}
+	return true
 }
 
 // HasSynced is required for the cache.Controller interface.
@@ -186,6 +1037,13 @@ func (c *Controller) LastSyncResourceVersion() string {
 }
 
 func (c *Controller) runWorker() {
+	if !IsLeader() {
+		// Standby: leave items queued and keep the informer's cache warm,
+		// but don't drain the queue or post to Mattermost until this
+		// replica wins an election. wait.Until re-enters runWorker every
+		// second, so this is just a poll, not a permanent stall.
+		return
+	}
 	for c.processNextItem() {
 		// continue looping
 	}
@@ -202,7 +1060,7 @@ func (c *Controller) processNextItem() bool {
 	if err == nil {
 		// No error, reset the ratelimit counters
 		c.queue.Forget(key)
-	} else if c.queue.NumRequeues(key) < maxRetries {
+	} else if c.queue.NumRequeues(key) < k8sRetryPolicy.MaxRetries {
 		c.logger.Errorf("Error processing %s (will retry): %v", key, err)
 		c.queue.AddRateLimited(key)
 	} else {
@@ -217,37 +1075,545 @@ func (c *Controller) processNextItem() bool {
 
 func (c *Controller) processItem(key string) error {
 	//c.logger.Infof("Processing change to Pod %s", key)
-	mm := &mattermostapi.MatterMost{
-		Url:         "http://localhost:8065",
-		UserName:    "veli",
-		Password:    "12345",
-		TeamName:    "devops",
-		ChannelName: "DevopsBot",
-	}
-
-	client := mm.GetClient()
 	Obj, exists, err := c.informer.GetIndexer().GetByKey(key)
 	if err != nil {
 		return fmt.Errorf("Error fetching object with key %s from store: %v", key, err)
 	}
+	if exists {
+		if metaObj, ok := Obj.(meta_v1.Object); ok {
+			checkpoint.Default.Set(c.kind, metaObj.GetResourceVersion())
+		}
+	}
+
+	if c.genericDynamic {
+		return c.processGenericDynamicItem(key, Obj, exists)
+	}
+
+	switch c.kind {
+	case "Pod":
+		return c.processPodItem(key, Obj, exists)
+	case "StatefulSet", "DaemonSet":
+		return c.processWorkloadItem(key, Obj, exists)
+	case "Job":
+		return c.processJobItem(key, Obj, exists)
+	case "Service":
+		return c.processServiceItem(key, Obj, exists)
+	case "Ingress":
+		return c.processIngressItem(key, Obj, exists)
+	case "Node":
+		return c.processNodeItem(key, Obj, exists)
+	case "PersistentVolumeClaim":
+		return c.processPVCItem(key, Obj, exists)
+	case "PersistentVolume":
+		return c.processPVItem(key, Obj, exists)
+	case "ConfigMap":
+		return c.processConfigMapItem(key, Obj, exists)
+	case "Secret":
+		return c.processSecretItem(key, Obj, exists)
+	case "ServiceAccount":
+		return c.processServiceAccountItem(key, Obj, exists)
+	case "Event":
+		return c.processEventItem(key, Obj, exists)
+	case "Endpoints":
+		return c.processEndpointsItem(key, Obj, exists)
+	case "DeploymentConfig":
+		return c.processDeploymentConfigItem(key, Obj, exists)
+	case "Route":
+		return c.processRouteItem(key, Obj, exists)
+	case "BuildConfig":
+		return c.processBuildConfigItem(key, Obj, exists)
+	case "Rollout":
+		return c.processRolloutItem(key, Obj, exists)
+	case "PodDisruptionBudget":
+		return c.processPDBItem(key, Obj, exists)
+	default:
+		return c.processDeploymentItem(key, Obj, exists)
+	}
+}
+
+func (c *Controller) processDeploymentItem(key string, Obj interface{}, exists bool) error {
+	mm := sharedMM.WithChannel("DevopsBot")
 
 	if !exists {
 		//fmt.Printf(obj)
 		//c.eventHandler.ObjectDeleted(obj)
 		//fmt.Print(obj)
 		fmt.Println("Pod terminated - ", key)
-		mm.PostMessage(client, "DeployBot - Deleted Deployment in OCP - "+key)
+		impact := ""
+		if ns, name, err := cache.SplitMetaNamespaceKey(key); err == nil {
+			configRefs.untrackDeployment(ns, name)
+			impact = ImpactSuffix(name)
+			deps.untrackDeployment(name)
+		}
+		client := mm.GetClient()
+		message := renderMessage("deployment.deleted", struct{ Key, Impact string }{key, impact}, "DeployBot - Deleted Deployment in OCP - "+key+impact)
+		e := rules.Event{Kind: "Deployment", Name: key, Reason: "Deleted", Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
 
 		return nil
 	}
 	//fmt.Print(obj)
 	//fmt.Println("Created a new Pod ", key, Obj.(*api_v1.Pod).Name, " Container Name - ", Obj.(*api_v1.Pod).Spec.Containers[0].Name, " Image Name - ", Obj.(*api_v1.Pod).Spec.Containers[0].Image)
-	fmt.Println("Created a new Deployment ", Obj.(*appsv1beta1.Deployment).Name)
+	namespace, name, annotations, created, ok := deploymentMeta(Obj)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+	fmt.Println("Created a new Deployment ", name)
+
+	if podSpec, ok := deploymentPodSpec(Obj); ok {
+		configRefs.trackDeployment(namespace, name, podSpec)
+	}
+	deps.trackDeployment(name, parseDependsOn(annotations[annotationDependsOn], name))
+
+	if annotations[annotationNotify] == "false" {
+		c.logger.Infof("Skipping notification for %s, opted out via %s annotation", name, annotationNotify)
+		return nil
+	}
+	if channel, ok := annotations[annotationChannel]; ok && channel != "" {
+		mm.ChannelName = channel
+	}
+	client := mm.GetClient()
+
+	if diff, ok := c.deployDiffs[key]; ok {
+		delete(c.deployDiffs, key)
+		if counts, ok := c.scaleEvents[key]; ok {
+			delete(c.scaleEvents, key)
+			message := fmt.Sprintf("DeployBot - Deployment %s scaled from %d -> %d replicas", name, counts[0], counts[1])
+			e := rules.Event{Namespace: namespace, Kind: "Deployment", Name: name, Reason: "Scaled", Message: message}
+			e.CorrelationID = c.notify(mm, client, message, e)
+			c.runRules(e)
+			return nil
+		}
+		message := renderMessage("deployment.updated", struct{ Name, Diff string }{name, diff}, fmt.Sprintf("DeployBot - Deployment %s updated - %s", name, diff))
+		e := rules.Event{Namespace: namespace, Kind: "Deployment", Name: name, Reason: "Updated", Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+		return nil
+	}
 
-	mm.PostMessage(client, "DeployBot - Created a new Deployment in OCP - "+Obj.(*appsv1beta1.Deployment).Name)
+	if start, pending := c.rolloutStarts[key]; pending {
+		c.reportRolloutOutcome(mm, client, key, namespace, name, start, Obj)
+		return nil
+	}
+
+	createdMessage := renderMessage("deployment.created", struct{ Name string }{name}, "DeployBot - Created a new Deployment in OCP - "+name)
+	e := rules.Event{
+		Namespace: namespace,
+		Kind:      "Deployment",
+		Name:      name,
+		Reason:    "Created",
+		Message:   createdMessage,
+	}
+	if c.rules != nil {
+		e.Severity = c.rules.Classify(e)
+	}
+	image := ""
+	if podSpec, ok := deploymentPodSpec(Obj); ok && len(podSpec.Containers) > 0 {
+		image = podSpec.Containers[0].Image
+	}
+	replicas, _ := deploymentReplicaCount(Obj)
+	attachment := mattermostapi.Attachment{
+		Color:     mattermostapi.SeverityColor(string(e.Severity)),
+		Title:     name,
+		TitleLink: dashboardLink(namespace, name),
+		Fields: []mattermostapi.AttachmentField{
+			{Title: "Namespace", Value: namespace, Short: true},
+			{Title: "Cluster", Value: clusterName, Short: true},
+			{Title: "Image", Value: image, Short: true},
+			{Title: "Replicas", Value: fmt.Sprintf("%d", replicas), Short: true},
+		},
+	}
+	e.CorrelationID = c.notifyAttachment(mm, client, createdMessage, e, attachment)
+	c.latency.Observe(mm.ChannelName, created, time.Now())
+	c.runRules(e)
 	return nil
 }
 
+// reportRolloutOutcome posts a success or failure message once a rollout
+// this controller is tracking (one that changed the pod spec, via
+// rolloutStarts) has been observed at its latest generation. It's a no-op,
+// other than leaving the rollout tracked, if the rollout is still in
+// progress.
+func (c *Controller) reportRolloutOutcome(mm *mattermostapi.MatterMost, client *model.Client, key, namespace, name string, start time.Time, obj interface{}) {
+	status, ok := deploymentRolloutStatus(obj)
+	if !ok {
+		return
+	}
+	done, succeeded, reason := rolloutOutcome(status)
+	desired, _ := deploymentReplicaCount(obj)
+	c.reportRolloutProgress(mm, client, obj, name, status.ReadyReplicas, desired, done)
+	if !done {
+		return
+	}
+	delete(c.rolloutStarts, key)
+	elapsed := time.Since(start).Round(time.Second)
+
+	usage := deploymentResourceUsageLine(c.clientset, namespace, obj)
+
+	if succeeded {
+		message := fmt.Sprintf("DeployBot - rollout of %s succeeded in %s", name, elapsed)
+		if usage != "" {
+			message += " - current usage vs request/limit: " + usage
+		}
+		e := rules.Event{Namespace: namespace, Kind: "Deployment", Name: name, Reason: "RolloutSucceeded", Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+		return
+	}
+
+	message := fmt.Sprintf("DeployBot - rollout of %s failed: %s%s", name, reason, ImpactSuffix(name))
+	if usage != "" {
+		message += " - current usage vs request/limit: " + usage
+	}
+	e := rules.Event{Namespace: namespace, Kind: "Deployment", Name: name, Reason: "RolloutFailed", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+}
+
+// reportRolloutProgress keeps a single Mattermost post, tracked by the
+// Deployment's UID in rolloutPosts, updated in place as a rollout's ready
+// replica count changes - "3/6 replicas ready" becoming "6/6 replicas ready
+// ✅" - instead of posting a new message for every status update in
+// between. The post is left in place (with done's checkmark, if reached)
+// once the rollout finishes; reportRolloutOutcome's own success/failure
+// message, with elapsed time and resource usage, still posts separately.
+func (c *Controller) reportRolloutProgress(mm *mattermostapi.MatterMost, client *model.Client, obj interface{}, name string, ready, desired int32, done bool) {
+	uid, ok := deploymentUID(obj)
+	if !ok {
+		return
+	}
+	line := progressLine(name, ready, desired, done)
+
+	if postID, tracked := c.rolloutPosts[uid]; tracked {
+		if mm.EditPost(client, postID, line) != nil && done {
+			delete(c.rolloutPosts, uid)
+		}
+		return
+	}
+
+	post := mm.PostMessage(client, line)
+	if post == nil {
+		return
+	}
+	if done {
+		return
+	}
+	c.rolloutPosts[uid] = post.Id
+}
+
+func (c *Controller) processPodItem(key string, Obj interface{}, exists bool) error {
+	mm := sharedMM.WithChannel("DevopsBot")
+
+	if !exists {
+		fmt.Println("Pod terminated - ", key)
+		client := mm.GetClient()
+		e := rules.Event{Kind: "Pod", Name: key, Reason: "Deleted", Message: "Terminated Pod in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Terminated Pod in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	pod, ok := Obj.(*api_v1.Pod)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	if upgradewatch.Active() && pod.DeletionTimestamp != nil {
+		upgradewatch.RecordEviction(pod.Namespace, pod.Name, pod.Spec.NodeName)
+		client := mm.GetClient()
+		message := fmt.Sprintf("DeployBot - upgrade-watch: pod %s/%s terminating on node %s", pod.Namespace, pod.Name, pod.Spec.NodeName)
+		e := rules.Event{Namespace: pod.Namespace, Kind: "Pod", Name: pod.Name, Reason: "Eviction", Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+		return nil
+	}
+
+	if events, ok := c.oomEvents[key]; ok {
+		delete(c.oomEvents, key)
+		client := mm.GetClient()
+		for _, ev := range events {
+			message := fmt.Sprintf("DeployBot - container %s in Pod %s/%s was OOMKilled (memory limit: %s)", ev.Container, pod.Namespace, pod.Name, ev.MemoryLimit)
+			if ev.Deployment != "" {
+				message += fmt.Sprintf(", Deployment %s", ev.Deployment)
+			}
+			if info := ev.Node.String(); info != "" {
+				message += fmt.Sprintf(" [node: %s]", info)
+			}
+			if usage := podResourceUsageLine(pod); usage != "" {
+				message += " - " + usage
+			}
+			e := rules.Event{Namespace: pod.Namespace, Kind: "Pod", Name: pod.Name, Reason: "OOMKilled", Message: message}
+			e.CorrelationID = c.notify(mm, client, message, e)
+			c.runRules(e)
+		}
+		return nil
+	}
+
+	if events, ok := c.crashLoopEvents[key]; ok {
+		delete(c.crashLoopEvents, key)
+		client := mm.GetClient()
+		for _, ev := range events {
+			message := fmt.Sprintf("DeployBot - container %s in Pod %s/%s is in CrashLoopBackOff (%d restarts)", ev.Container, pod.Namespace, pod.Name, ev.RestartCount)
+			e := rules.Event{Namespace: pod.Namespace, Kind: "Pod", Name: pod.Name, Reason: "CrashLoopBackOff", Message: message}
+			e.CorrelationID = c.notify(mm, client, message, e)
+			c.runRules(e)
+
+			if logs, err := crashLoopLogs(c.clientset, pod.Namespace, pod.Name, ev.Container); err != nil {
+				fmt.Println("controller: couldn't fetch crash-loop logs for", pod.Namespace, pod.Name, ev.Container, ":", err)
+			} else {
+				filename := fmt.Sprintf("%s-%s.log", pod.Name, ev.Container)
+				mm.PostFile(client, filename, logs, fmt.Sprintf("DeployBot - last %d lines of %s/%s logs", crashLoopLogTailLines, pod.Name, ev.Container))
+			}
+		}
+		return nil
+	}
+
+	var image string
+	if len(pod.Spec.Containers) > 0 {
+		image = pod.Spec.Containers[0].Image
+	}
+	fmt.Println("Created a new Pod ", pod.Name, " Image - ", image)
+
+	client := mm.GetClient()
+	message := "DeployBot - Created a new Pod in OCP - " + pod.Name + " (image: " + image + ")"
+	e := rules.Event{
+		Namespace: pod.Namespace,
+		Kind:      "Pod",
+		Name:      pod.Name,
+		Reason:    "Created",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.latency.Observe(mm.ChannelName, pod.CreationTimestamp.Time, time.Now())
+	c.runRules(e)
+	return nil
+}
+
+// processWorkloadItem handles StatefulSets and DaemonSets with the same
+// add/delete notification flow as processDeploymentItem.
+func (c *Controller) processWorkloadItem(key string, Obj interface{}, exists bool) error {
+	mm := sharedMM.WithChannel("DevopsBot")
+
+	if !exists {
+		fmt.Println(c.kind+" terminated - ", key)
+		client := mm.GetClient()
+		e := rules.Event{Kind: c.kind, Name: key, Reason: "Deleted", Message: "Deleted " + c.kind + " in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted "+c.kind+" in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	namespace, name, created, ok := workloadMeta(Obj)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+	fmt.Println("Created a new "+c.kind+" ", name)
+
+	client := mm.GetClient()
+	message := "DeployBot - Created a new " + c.kind + " in OCP - " + name
+	e := rules.Event{
+		Namespace: namespace,
+		Kind:      c.kind,
+		Name:      name,
+		Reason:    "Created",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.latency.Observe(mm.ChannelName, created, time.Now())
+	c.runRules(e)
+	return nil
+}
+
+// notify posts message to mm's channel and tracks the resulting post under
+// category so a later 👍/👎 reaction can be attributed to it by `!feedback
+// report`. It also mints a correlation ID for this notification, logs it
+// alongside category and message, and returns it so the caller can pass
+// the same ID into runRules - tying the chat message, any rule's webhook
+// payload, and DeployBot's own logs for this event together.
+func (c *Controller) notify(mm *mattermostapi.MatterMost, client *model.Client, message string, e rules.Event) string {
+	corrID, _ := c.notifyThreaded(mm, client, message, e, "")
+	return corrID
+}
+
+// notifyThreaded is notify, but posts as a reply under rootID instead of a
+// new top-level message when rootID is non-empty - used to collapse a
+// series of related events (e.g. every crashing Pod under one Deployment)
+// into a single Mattermost thread. Returns the resulting post's ID
+// alongside the correlation ID, so a caller opening a new thread can
+// remember it as future events' rootID.
+//
+// e is checked against notificationFilters and any open blackout window
+// before posting - either one suppresses the chat post (postID comes back
+// "") without affecting the correlation ID or the log line, so the
+// event's trail through incident.DefaultLog and runRules stays intact
+// even when the chat message itself was held back.
+func (c *Controller) notifyThreaded(mm *mattermostapi.MatterMost, client *model.Client, message string, e rules.Event, rootID string) (corrID, postID string) {
+	corrID, category, text, suppressed := c.prepareNotify(message, e)
+	if suppressed {
+		return corrID, ""
+	}
+
+	var post *model.Post
+	if rootID != "" {
+		post = mm.PostReply(client, rootID, text)
+	} else {
+		post = mm.PostMessage(client, text)
+	}
+	if post != nil {
+		feedback.Track(post.Id, category)
+		postID = post.Id
+	}
+	trackOpenAlert(corrID, mm, client, category, e.Namespace, e.Name, postID)
+	return corrID, postID
+}
+
+// notifyAttachment is notify, but posts message as a rich, colored
+// attachment (see mattermostapi.Attachment) instead of one long plain-text
+// sentence - for events structured enough to be worth it (so far: a new
+// Deployment's namespace/cluster/image/replicas).
+func (c *Controller) notifyAttachment(mm *mattermostapi.MatterMost, client *model.Client, message string, e rules.Event, a mattermostapi.Attachment) string {
+	corrID, category, text, suppressed := c.prepareNotify(message, e)
+	if suppressed {
+		return corrID
+	}
+
+	post := mm.PostAttachment(client, "", text, a)
+	var postID string
+	if post != nil {
+		feedback.Track(post.Id, category)
+		postID = post.Id
+	}
+	trackOpenAlert(corrID, mm, client, category, e.Namespace, e.Name, postID)
+	return corrID
+}
+
+// prepareNotify runs the correlation-ID/category/logging/blackout/filter/tag
+// steps shared by notify, notifyThreaded, and notifyAttachment, returning
+// the ref-tagged text ready to post and whether the caller should suppress
+// posting (blacked out or filtered) even though the correlation ID and log
+// line it already recorded stay valid.
+func (c *Controller) prepareNotify(message string, e rules.Event) (corrID, category, text string, suppressed bool) {
+	corrID = string(uuid.NewUUID())
+	category = e.Kind + "/" + e.Reason
+	c.logger.WithFields(logrus.Fields{"correlation_id": corrID, "category": category}).Info(message)
+
+	if blackoutActive() {
+		recordSuppressed(category)
+		return corrID, category, "", true
+	}
+	if !shouldNotify(e) {
+		return corrID, category, "", true
+	}
+
+	if notificationTag != "" {
+		message = notificationTag + " " + message
+	}
+	return corrID, category, fmt.Sprintf("%s _(ref: %s)_", message, corrID), false
+}
+
+// notificationFilters holds expressions - same narrow ==, !=, && subset
+// rules.EvalExpr supports - that an event must all satisfy before its chat
+// notification is posted, e.g. "Namespace != dev" to keep dev-cluster noise
+// out of chat while severity classification, incident logging, and rule
+// actions still run normally for it. Overridden via SetNotificationFilters
+// before Start(); empty (the default) notifies unfiltered, same as before
+// this existed.
+var notificationFilters []string
+
+// SetNotificationFilters overrides notificationFilters for every
+// controller's notify call after this point.
+func SetNotificationFilters(filters []string) {
+	notificationFilters = filters
+}
+
+// shouldNotify reports whether e passes every configured notification
+// filter. A filter that fails to parse is treated as not matching -
+// suppressing the notification - the same conservative default
+// rules.Rule.Matches falls back to for a bad Expr, so a typo in a filter
+// fails closed (quiet) rather than open (spammy).
+func shouldNotify(e rules.Event) bool {
+	for _, expr := range notificationFilters {
+		ok, err := rules.EvalExpr(expr, e)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runRules records e on the incident timeline, classifies its Severity
+// against the controller's rule set, then fans out any matched rule's
+// actions (webhook, ticket, runbook) in addition to the plain chat message
+// already posted by processItem. A SeverityCritical classification also
+// gets an @here escalation post of its own - critical events shouldn't
+// depend on someone reading every line DeployBot posts to notice them.
+func (c *Controller) runRules(e rules.Event) {
+	if e.CorrelationID == "" {
+		// Events built for rule testing (!test-rule) or without a
+		// preceding notify call still get an ID, so nothing downstream
+		// has to special-case an empty one.
+		e.CorrelationID = string(uuid.NewUUID())
+	}
+
+	if c.rules != nil {
+		e.Severity = c.rules.Classify(e)
+	}
+
+	incident.DefaultLog.Append(incident.Record{
+		Time:          time.Now(),
+		Namespace:     e.Namespace,
+		Kind:          e.Kind,
+		Name:          e.Name,
+		Reason:        e.Reason,
+		Message:       e.Message,
+		CorrelationID: e.CorrelationID,
+	})
+
+	if c.rules == nil {
+		return
+	}
+	for _, matched := range c.rules.Evaluate(e) {
+		c.dispatcher.Run(matched, e)
+	}
+	if e.Severity == rules.SeverityCritical {
+		c.escalate(e)
+	}
+}
+
+// escalate posts an @here follow-up for an event a rule classified
+// SeverityCritical. Routing it to a dedicated channel per severity would
+// hit the same hardcoded-ChannelName limitation notificationTag's doc
+// comment already describes for per-environment routing, so this posts to
+// the DevopsBot channel, whichever channel the original notification went
+// to - just with a mention that doesn't wait for someone to scroll past it. Skipped if the
+// original notification's post already has a 👀/✅ ack.Add reaction on it -
+// a human has already seen (or resolved) the alert, so the @here follow-up
+// would just be noise.
+func (c *Controller) escalate(e rules.Event) {
+	if ack.Acknowledged(openAlertPostID(e.CorrelationID)) {
+		return
+	}
+	mm := openshiftMatterMost()
+	client := mm.GetClient()
+	mm.PostMessage(client, fmt.Sprintf("@here DeployBot - critical: %s _(ref: %s)_", e.Message, e.CorrelationID))
+}
+
+// splitNonEmpty splits a comma-separated env var into its non-empty parts.
+func splitNonEmpty(csv string) []string {
+	if csv == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(csv, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func homeDir() string {
 	if h := os.Getenv("HOME"); h != "" {
 		return h
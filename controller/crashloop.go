@@ -0,0 +1,58 @@
+package controller
+
+import (
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// crashLoopLogTailLines bounds how much of a crash-looping container's log
+// gets attached to the alert - enough to show the actual failure, short
+// enough to stay a reasonably sized upload.
+const crashLoopLogTailLines = int64(200)
+
+// crashLoopEvent is one container observed entering CrashLoopBackOff
+// between two observations of a Pod.
+type crashLoopEvent struct {
+	Container    string
+	RestartCount int32
+}
+
+// newCrashLoops compares old and new Pod container statuses and returns a
+// crashLoopEvent for every container whose Waiting.Reason just became
+// CrashLoopBackOff - i.e. it wasn't already in that state in old, so a
+// container stuck crash-looping is reported once per backoff cycle it
+// enters, not on every resync while it stays there.
+func newCrashLoops(old, new *api_v1.Pod) []crashLoopEvent {
+	wasCrashLooping := map[string]bool{}
+	for _, cs := range old.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+			wasCrashLooping[cs.Name] = true
+		}
+	}
+
+	var events []crashLoopEvent
+	for _, cs := range new.Status.ContainerStatuses {
+		if cs.State.Waiting == nil || cs.State.Waiting.Reason != "CrashLoopBackOff" {
+			continue
+		}
+		if wasCrashLooping[cs.Name] {
+			continue
+		}
+		events = append(events, crashLoopEvent{Container: cs.Name, RestartCount: cs.RestartCount})
+	}
+	return events
+}
+
+// crashLoopLogs returns the last crashLoopLogTailLines of container's log
+// in namespace/pod, preferring the previous (crashed) run's log over the
+// current one since that's what actually explains the crash.
+func crashLoopLogs(clientset kubernetes.Interface, namespace, pod, container string) ([]byte, error) {
+	tail := crashLoopLogTailLines
+	opts := &api_v1.PodLogOptions{Container: container, Previous: true, TailLines: &tail}
+	logs, err := clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).DoRaw()
+	if err != nil {
+		opts.Previous = false
+		logs, err = clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).DoRaw()
+	}
+	return logs, err
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// deploymentReplicas returns a Deployment's configured replica count.
+func deploymentReplicas(obj interface{}) *int32 {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		return d.Spec.Replicas
+	case *appsv1beta1.Deployment:
+		return d.Spec.Replicas
+	default:
+		return nil
+	}
+}
+
+// scaledOnly reports whether diffs (as returned by diffDeploymentSpec)
+// describes nothing but a replica count change, so the caller can
+// distinguish a plain scale event (manual kubectl scale or HPA-driven)
+// from an image rollout that happens to also change replica count.
+func scaledOnly(diffs []string) bool {
+	return len(diffs) == 1 && strings.HasPrefix(diffs[0], "replicas ")
+}
+
+func int32Value(p *int32) int32 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// diffDeploymentSpec compares two Deployment pod specs and replica counts,
+// returning a human-readable description of every image, replica, env, and
+// resource request change - the same four fields kubectl diff usually gets
+// asked about after a bad rollout.
+func diffDeploymentSpec(oldSpec, newSpec api_v1.PodSpec, oldReplicas, newReplicas *int32) []string {
+	var changes []string
+
+	if int32Value(oldReplicas) != int32Value(newReplicas) {
+		changes = append(changes, fmt.Sprintf("replicas %d -> %d", int32Value(oldReplicas), int32Value(newReplicas)))
+	}
+
+	oldContainers := containersByName(oldSpec)
+	newContainers := containersByName(newSpec)
+
+	names := make([]string, 0, len(newContainers))
+	for name := range newContainers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		newC := newContainers[name]
+		oldC, ok := oldContainers[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("container %s added (image %s)", name, newC.Image))
+			continue
+		}
+		if oldC.Image != newC.Image {
+			if oldTag, newTag := imageTag(oldC.Image), imageTag(newC.Image); oldTag != newTag {
+				changes = append(changes, fmt.Sprintf("%s: %s → %s", name, oldTag, newTag))
+			} else {
+				changes = append(changes, fmt.Sprintf("%s image %s -> %s", name, oldC.Image, newC.Image))
+			}
+		}
+		if envChange := diffEnv(oldC.Env, newC.Env); envChange != "" {
+			changes = append(changes, fmt.Sprintf("%s env %s", name, envChange))
+		}
+		if resChange := diffResources(oldC.Resources, newC.Resources); resChange != "" {
+			changes = append(changes, fmt.Sprintf("%s resources %s", name, resChange))
+		}
+	}
+	for name := range oldContainers {
+		if _, ok := newContainers[name]; !ok {
+			changes = append(changes, fmt.Sprintf("container %s removed", name))
+		}
+	}
+
+	return changes
+}
+
+// imageTag extracts the tag portion of a container image reference - the
+// part after the last colon in its final path segment - defaulting to
+// "latest" when the reference has none, so deploy notifications read like
+// "payments-api: v1.4.2 → v1.4.3" instead of spelling out the full
+// registry path on both sides.
+func imageTag(image string) string {
+	last := image
+	if idx := strings.LastIndex(image, "/"); idx >= 0 {
+		last = image[idx+1:]
+	}
+	if idx := strings.LastIndex(last, ":"); idx >= 0 {
+		return last[idx+1:]
+	}
+	return "latest"
+}
+
+func containersByName(spec api_v1.PodSpec) map[string]api_v1.Container {
+	out := make(map[string]api_v1.Container, len(spec.Containers))
+	for _, c := range spec.Containers {
+		out[c.Name] = c
+	}
+	return out
+}
+
+func envMap(env []api_v1.EnvVar) map[string]string {
+	out := make(map[string]string, len(env))
+	for _, e := range env {
+		switch {
+		case e.ValueFrom == nil:
+			out[e.Name] = e.Value
+		case e.ValueFrom.ConfigMapKeyRef != nil:
+			out[e.Name] = "configMapKeyRef:" + e.ValueFrom.ConfigMapKeyRef.Name + "/" + e.ValueFrom.ConfigMapKeyRef.Key
+		case e.ValueFrom.SecretKeyRef != nil:
+			out[e.Name] = "secretKeyRef:" + e.ValueFrom.SecretKeyRef.Name + "/" + e.ValueFrom.SecretKeyRef.Key
+		default:
+			out[e.Name] = "valueFrom"
+		}
+	}
+	return out
+}
+
+// diffEnv summarizes added/removed/changed env vars between two containers,
+// naming the var but never its value if it comes from a Secret, since that
+// value shouldn't be echoed into chat.
+func diffEnv(old, new []api_v1.EnvVar) string {
+	oldEnv := envMap(old)
+	newEnv := envMap(new)
+
+	var added, removed, changed []string
+	for name, v := range newEnv {
+		if ov, ok := oldEnv[name]; !ok {
+			added = append(added, name)
+		} else if ov != v {
+			changed = append(changed, name)
+		}
+	}
+	for name := range oldEnv {
+		if _, ok := newEnv[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, "added ["+strings.Join(added, ",")+"]")
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "removed ["+strings.Join(removed, ",")+"]")
+	}
+	if len(changed) > 0 {
+		parts = append(parts, "changed ["+strings.Join(changed, ",")+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func resourceListString(r api_v1.ResourceList) string {
+	if len(r) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(r))
+	if cpu, ok := r[api_v1.ResourceCPU]; ok {
+		parts = append(parts, "cpu="+cpu.String())
+	}
+	if mem, ok := r[api_v1.ResourceMemory]; ok {
+		parts = append(parts, "memory="+mem.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// diffResources summarizes a change in a container's resource requests or
+// limits.
+func diffResources(old, new api_v1.ResourceRequirements) string {
+	var parts []string
+	if oldReq, newReq := resourceListString(old.Requests), resourceListString(new.Requests); oldReq != newReq {
+		parts = append(parts, fmt.Sprintf("requests %s -> %s", oldReq, newReq))
+	}
+	if oldLim, newLim := resourceListString(old.Limits), resourceListString(new.Limits); oldLim != newLim {
+		parts = append(parts, fmt.Sprintf("limits %s -> %s", oldLim, newLim))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// depTracker remembers each Deployment's declared dependencies (via the
+// deploybot.io/depends-on annotation) in both directions: forward, for
+// !deps to render a tree, and reverse, so an alert about a Deployment
+// going down can name the downstream services that depend on it.
+type depTracker struct {
+	mu         sync.Mutex
+	dependsOn  map[string][]string         // deployment -> services it depends on, in annotation order
+	dependents map[string]map[string]bool // service -> set of deployments that depend on it
+}
+
+// deps is process-wide, like configRefs, since it's populated by the
+// Deployment controller and read by the !deps command independently.
+var deps = newDepTracker()
+
+func newDepTracker() *depTracker {
+	return &depTracker{
+		dependsOn:  make(map[string][]string),
+		dependents: make(map[string]map[string]bool),
+	}
+}
+
+// trackDeployment records name's declared dependencies, replacing whatever
+// it previously recorded for name.
+func (t *depTracker) trackDeployment(name string, dependsOn []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.untrackLocked(name)
+	if len(dependsOn) == 0 {
+		return
+	}
+	t.dependsOn[name] = dependsOn
+	for _, dep := range dependsOn {
+		if t.dependents[dep] == nil {
+			t.dependents[dep] = make(map[string]bool)
+		}
+		t.dependents[dep][name] = true
+	}
+}
+
+// untrackDeployment removes every dependency recorded for a deployment that
+// no longer exists.
+func (t *depTracker) untrackDeployment(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.untrackLocked(name)
+}
+
+func (t *depTracker) untrackLocked(name string) {
+	for _, dep := range t.dependsOn[name] {
+		delete(t.dependents[dep], name)
+		if len(t.dependents[dep]) == 0 {
+			delete(t.dependents, dep)
+		}
+	}
+	delete(t.dependsOn, name)
+}
+
+// dependentsOf returns the names of the deployments (if any) declared to
+// depend on name, sorted for stable messages.
+func (t *depTracker) dependentsOf(name string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, 0, len(t.dependents[name]))
+	for d := range t.dependents[name] {
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// dependenciesOf returns the services name declared via depends-on, in the
+// order the annotation listed them.
+func (t *depTracker) dependenciesOf(name string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, len(t.dependsOn[name]))
+	copy(out, t.dependsOn[name])
+	return out
+}
+
+// parseDependsOn splits a deploybot.io/depends-on annotation value into its
+// comma-separated service names, trimming whitespace and dropping empty
+// entries and a self-reference (a Deployment can't meaningfully depend on
+// itself, and allowing it would make Tree loop immediately).
+func parseDependsOn(value, self string) []string {
+	if value == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		name := strings.TrimSpace(part)
+		if name == "" || name == self {
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// ImpactSuffix returns " (affects a, b)" naming the services declared to
+// depend on name, or "" if nothing depends on it - for an alert about name
+// going down to name what it takes with it.
+func ImpactSuffix(name string) string {
+	affected := deps.dependentsOf(name)
+	if len(affected) == 0 {
+		return ""
+	}
+	return " (affects " + strings.Join(affected, ", ") + ")"
+}
+
+// DependencyTree renders name's declared dependencies as an indented tree,
+// for the !deps command. A dependency already rendered anywhere earlier in
+// the tree (not only on the current branch) is marked "(cycle)" instead of
+// being expanded again, so two services declaring each other as
+// dependencies can't loop forever; depth additionally cuts the tree off
+// past maxDependencyTreeDepth levels.
+func DependencyTree(name string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	renderDependencyTree(&b, name, 1, map[string]bool{name: true})
+	return b.String()
+}
+
+const maxDependencyTreeDepth = 10
+
+func renderDependencyTree(b *strings.Builder, name string, depth int, seen map[string]bool) {
+	if depth > maxDependencyTreeDepth {
+		return
+	}
+	for _, dep := range deps.dependenciesOf(name) {
+		b.WriteString("\n" + strings.Repeat("  ", depth) + "- " + dep)
+		if seen[dep] {
+			b.WriteString(" (cycle)")
+			continue
+		}
+		seen[dep] = true
+		renderDependencyTree(b, dep, depth+1, seen)
+	}
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// downtimeDigest combines every controller's drainInitialQueue result into
+// one severity-sorted post instead of each controller announcing its own
+// "resumed after downtime" message - a dozen kinds restarting at once used
+// to mean a dozen separate catch-up posts.
+type downtimeDigest struct {
+	mu       sync.Mutex
+	expected int
+	reported int
+	since    time.Duration
+	haveSize bool
+	entries  []downtimeEntry
+	posted   bool
+}
+
+type downtimeEntry struct {
+	Kind  string
+	Count int
+}
+
+var digest = &downtimeDigest{}
+
+// beginDowntimeDigest resets the digest for a new run: expected is how many
+// controllers will report in (one per watched kind), since is how long
+// DeployBot was down (zero/ignored if this is the first run and there's
+// nothing to report).
+func beginDowntimeDigest(expected int, since time.Duration, haveSince bool) {
+	digest.mu.Lock()
+	defer digest.mu.Unlock()
+	digest.expected = expected
+	digest.reported = 0
+	digest.since = since
+	digest.haveSize = haveSince
+	digest.entries = nil
+	digest.posted = false
+}
+
+// reportDrainResult is drainInitialQueue's hand-off to the shared digest:
+// once every expected controller has reported in, the digest posts itself.
+func reportDrainResult(kind string, missed int) {
+	digest.mu.Lock()
+	if missed > 0 {
+		digest.entries = append(digest.entries, downtimeEntry{Kind: kind, Count: missed})
+	}
+	digest.reported++
+	ready := digest.reported >= digest.expected && !digest.posted
+	if ready {
+		digest.posted = true
+	}
+	entries := append([]downtimeEntry{}, digest.entries...)
+	since := digest.since
+	haveSince := digest.haveSize
+	digest.mu.Unlock()
+
+	if ready {
+		postDowntimeDigest(entries, since, haveSince)
+	}
+}
+
+// kindSeverity is a small, local significance ordering for the digest -
+// not a general severity engine, just enough to put the changes an
+// operator most likely cares about first.
+func kindSeverity(kind string) int {
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet":
+		return 3
+	case "Pod", "Job":
+		return 2
+	default:
+		return 1
+	}
+}
+
+func postDowntimeDigest(entries []downtimeEntry, since time.Duration, haveSince bool) {
+	if len(entries) == 0 {
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		si, sj := kindSeverity(entries[i].Kind), kindSeverity(entries[j].Kind)
+		if si != sj {
+			return si > sj
+		}
+		return entries[i].Count > entries[j].Count
+	})
+
+	message := "DeployBot - resumed"
+	if haveSince {
+		message += fmt.Sprintf(" after %s of downtime", since.Round(time.Second))
+	}
+	message += ". Changes while I was down, most significant first:"
+	for _, e := range entries {
+		message += fmt.Sprintf("\n- %s: %d object(s) changed", e.Kind, e.Count)
+	}
+
+	mm := sharedMM.WithChannel("DevopsBot")
+	client, err := mm.TryGetClient()
+	if err != nil {
+		logrus.WithError(err).Warn("downtime digest: couldn't get mattermost client")
+		return
+	}
+	corrID := string(uuid.NewUUID())
+	logrus.WithField("correlation_id", corrID).Info(message)
+	if notificationTag != "" {
+		message = notificationTag + " " + message
+	}
+	mm.PostMessage(client, fmt.Sprintf("%s _(ref: %s)_", message, corrID))
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"DeployBot/rules"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+// DynamicWatchSpec is an operator-configured resource to watch through the
+// dynamic client - e.g. Argo's Rollouts or cert-manager's Certificates -
+// without this package needing a typed clientset or a code change for
+// every CRD an install happens to care about.
+type DynamicWatchSpec struct {
+	GroupVersion string // e.g. "argoproj.io/v1alpha1"
+	Resource     string // plural, e.g. "rollouts"
+	Kind         string // becomes Controller.kind and the notification category
+	Namespaced   bool
+}
+
+// dynamicWatches is the set of operator-configured watches Start() builds
+// controllers for, alongside the hardcoded OCP ones. Set via
+// SetDynamicWatches before Start().
+var dynamicWatches []DynamicWatchSpec
+
+// SetDynamicWatches overrides dynamicWatches for the next Start() call.
+func SetDynamicWatches(specs []DynamicWatchSpec) {
+	dynamicWatches = specs
+}
+
+func newControllerDynamicWatch(restConfig *rest.Config, spec DynamicWatchSpec, nsFilter NamespaceFilter) (*Controller, error) {
+	res := dynamicResource{
+		GroupVersion: spec.GroupVersion,
+		Resource:     spec.Resource,
+		Kind:         spec.Kind,
+		Namespaced:   spec.Namespaced,
+	}
+	return newDynamicController(restConfig, res, nsFilter, true)
+}
+
+// processGenericDynamicItem renders add/update/delete notifications for a
+// Controller watching an operator-configured resource it has no
+// field-specific knowledge of - unlike processDeploymentConfigItem and its
+// siblings, it can't call out what changed, only that the object did.
+func (c *Controller) processGenericDynamicItem(key string, obj interface{}, exists bool) error {
+	mm := openshiftMatterMost()
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: c.kind, Name: key, Reason: "Deleted", Message: fmt.Sprintf("Deleted %s %s", c.kind, key)}
+		e.CorrelationID = c.notify(mm, client, fmt.Sprintf("DeployBot - Deleted %s %s", c.kind, key), e)
+		c.runRules(e)
+		return nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	message := fmt.Sprintf("DeployBot - %s %s changed (resource version %s)", c.kind, u.GetName(), u.GetResourceVersion())
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: u.GetNamespace(),
+		Kind:      c.kind,
+		Name:      u.GetName(),
+		Reason:    "Changed",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
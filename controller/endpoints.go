@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// defaultMinReadyEndpoints is the minimum ready-endpoint count a Service
+// must keep before DeployBot alerts, for Services with no override in
+// endpointMinReadyOverrides - i.e. alert only once it has none left.
+const defaultMinReadyEndpoints = 1
+
+// endpointMinReadyOverrides maps a Service's "namespace/name" key to its
+// configured minimum ready-endpoint count. Set via SetEndpointMinReady,
+// wired from config.Config.EndpointMinReady.
+var endpointMinReadyOverrides = map[string]int{}
+
+// SetEndpointMinReady lets main wire in config.Config.EndpointMinReady.
+func SetEndpointMinReady(overrides map[string]int) {
+	endpointMinReadyOverrides = overrides
+}
+
+func endpointMinReady(key string) int {
+	if min, ok := endpointMinReadyOverrides[key]; ok {
+		return min
+	}
+	return defaultMinReadyEndpoints
+}
+
+// endpointCounts tallies an Endpoints object's ready and not-ready
+// addresses, and the Pods backing the not-ready ones - the part a user
+// actually needs to go look at.
+type endpointCounts struct {
+	ready        int
+	notReadyPods []string
+}
+
+func countEndpoints(ep *api_v1.Endpoints) endpointCounts {
+	var c endpointCounts
+	for _, subset := range ep.Subsets {
+		c.ready += len(subset.Addresses)
+		for _, addr := range subset.NotReadyAddresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				c.notReadyPods = append(c.notReadyPods, addr.TargetRef.Name)
+			}
+		}
+	}
+	return c
+}
+
+// newControllerEndpoints watches core/v1 Endpoints, one per Service, to
+// alert when a Service's ready-endpoint count drops too low. Kubernetes'
+// newer EndpointSlice API would scale better for Services with very many
+// backing Pods, but k8s.io/api/discovery isn't vendored in this tree -
+// every Service still gets a classic Endpoints object, so this sees the
+// same signal either way.
+func newControllerEndpoints(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Endpoints(meta_v1.NamespaceAll).List(withFieldSelector("Endpoints", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Endpoints(meta_v1.NamespaceAll).Watch(withFieldSelector("Endpoints", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.Endpoints{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.Endpoints{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if ep, ok := obj.(*api_v1.Endpoints); ok && !nsFilter.Allowed(ep.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(old, new interface{}) { enqueue(new) },
+	})
+
+	return &Controller{
+		logger:          logrus.WithField("pkg", "kubewatch-endpoints"),
+		clientset:       client,
+		informer:        informer,
+		queue:           queue,
+		rules:           &rules.Engine{},
+		dispatcher:      rules.NewDispatcher(),
+		nsFilter:        nsFilter,
+		kind:            "Endpoints",
+		latency:         metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		endpointAlerted: make(map[string]bool),
+	}
+}
+
+// processEndpointsItem alerts once when a Service's ready-endpoint count
+// drops below its configured minimum, naming the not-ready Pods behind it,
+// and clears the alert once the Service recovers.
+func (c *Controller) processEndpointsItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		delete(c.endpointAlerted, key)
+		return nil
+	}
+
+	ep, ok := Obj.(*api_v1.Endpoints)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	counts := countEndpoints(ep)
+	minReady := endpointMinReady(key)
+
+	if counts.ready >= minReady {
+		delete(c.endpointAlerted, key)
+		return nil
+	}
+	if c.endpointAlerted[key] {
+		return nil
+	}
+	c.endpointAlerted[key] = true
+
+	mm := sharedMM.WithChannel("DevopsBot")
+	client := mm.GetClient()
+
+	message := fmt.Sprintf("DeployBot - Service %s has only %d ready endpoint(s) (minimum %d)", key, counts.ready, minReady)
+	if len(counts.notReadyPods) > 0 {
+		message += " - not ready: " + strings.Join(counts.notReadyPods, ", ")
+	}
+	e := rules.Event{Namespace: ep.Namespace, Kind: "Endpoints", Name: ep.Name, Reason: "Degraded", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
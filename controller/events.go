@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"DeployBot/incident"
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// eventRateLimitWindow bounds how often this bot will forward another
+// Warning event about the same object, since a single stuck Pod can emit
+// the same FailedScheduling/Unhealthy event every few seconds.
+const eventRateLimitWindow = 5 * time.Minute
+
+// eventRateLimiter throttles Warning event forwarding per involved object.
+type eventRateLimiter struct {
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+func newEventRateLimiter() *eventRateLimiter {
+	return &eventRateLimiter{lastSent: make(map[string]time.Time)}
+}
+
+// allow reports whether a Warning event for key/reason should be forwarded
+// right now, recording that it was if so.
+func (l *eventRateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.lastSent[key]; ok && time.Since(last) < eventRateLimitWindow {
+		return false
+	}
+	l.lastSent[key] = time.Now()
+	return true
+}
+
+// deployThreadWindow bounds how long a Deployment's event thread stays
+// open - a Warning event within this long of the thread's last one posts
+// as a reply under it; otherwise it starts a fresh thread, the same as
+// the very first event for that Deployment always does.
+const deployThreadWindow = 10 * time.Minute
+
+// threadRoot is one Deployment's currently open collapsing thread.
+type threadRoot struct {
+	PostID  string
+	Expires time.Time
+}
+
+// deployThreads collapses Pod/ReplicaSet Warning events that resolve to
+// the same Deployment into one Mattermost thread, so a rollout with many
+// crashing Pods reads as a single conversation instead of one message per
+// Pod.
+type deployThreads struct {
+	mu    sync.Mutex
+	roots map[string]threadRoot
+}
+
+func newDeployThreads() *deployThreads {
+	return &deployThreads{roots: make(map[string]threadRoot)}
+}
+
+// rootFor returns the still-open thread to post key's next event under,
+// extending its expiry, or "" if key needs a fresh top-level post.
+func (d *deployThreads) rootFor(key string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	root, ok := d.roots[key]
+	if !ok || time.Now().After(root.Expires) {
+		return ""
+	}
+	d.roots[key] = threadRoot{PostID: root.PostID, Expires: time.Now().Add(deployThreadWindow)}
+	return root.PostID
+}
+
+// open records postID as key's new thread root.
+func (d *deployThreads) open(key, postID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.roots[key] = threadRoot{PostID: postID, Expires: time.Now().Add(deployThreadWindow)}
+}
+
+func newControllerEvent(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Events(meta_v1.NamespaceAll).List(withFieldSelector("Event", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Events(meta_v1.NamespaceAll).Watch(withFieldSelector("Event", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.Event{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.Event{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if e, ok := obj.(*api_v1.Event); ok && !nsFilter.Allowed(e.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(old, new interface{}) { enqueue(new) },
+	})
+
+	return &Controller{
+		logger:        logrus.WithField("pkg", "kubewatch-event"),
+		clientset:     client,
+		informer:      informer,
+		queue:         queue,
+		rules:         &rules.Engine{},
+		dispatcher:    rules.NewDispatcher(),
+		nsFilter:      nsFilter,
+		kind:          "Event",
+		latency:       metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		eventRate:     newEventRateLimiter(),
+		deployThreads: newDeployThreads(),
+	}
+}
+
+// processEventItem forwards core Warning events (FailedScheduling,
+// ImagePullBackOff, Unhealthy, ...) to Mattermost, rate limited per involved
+// object so a flapping Pod doesn't spam the channel.
+func (c *Controller) processEventItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		return nil
+	}
+
+	event, ok := Obj.(*api_v1.Event)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+	if event.Type != api_v1.EventTypeWarning {
+		return nil
+	}
+
+	involved := event.InvolvedObject
+	rateKey := involved.Namespace + "/" + involved.Kind + "/" + involved.Name + "/" + event.Reason
+	if !c.eventRate.allow(rateKey) {
+		return nil
+	}
+
+	c.notifyWarningEvent(event)
+	return nil
+}
+
+// notifyWarningEvent posts event to Mattermost (collapsing Pod/ReplicaSet
+// events into their Deployment's open thread, same as always) and records
+// it on the incident timeline. Factored out of processEventItem so
+// replayRecentWarningEvents' startup catch-up can post through the same
+// path without going through the per-object rate limiter, which exists to
+// throttle a live flapping object, not a one-shot replay.
+func (c *Controller) notifyWarningEvent(event *api_v1.Event) {
+	involved := event.InvolvedObject
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+	message := renderMessage("pod.crash", struct{ Kind, Namespace, Name, Reason, Message string }{involved.Kind, involved.Namespace, involved.Name, event.Reason, event.Message},
+		fmt.Sprintf("DeployBot - WARNING: %s %s/%s - %s: %s", involved.Kind, involved.Namespace, involved.Name, event.Reason, event.Message))
+	e := rules.Event{Namespace: involved.Namespace, Kind: involved.Kind, Name: involved.Name, Reason: event.Reason, Message: message}
+
+	// Pod and ReplicaSet events that resolve to the same Deployment collapse
+	// into one thread instead of one top-level message per Pod, since a bad
+	// rollout can crash every one of its Pods within the same few seconds.
+	var threadKey, rootID string
+	if involved.Kind == "Pod" || involved.Kind == "ReplicaSet" {
+		if deployment := deploymentForInvolvedObject(c.clientset, involved); deployment != "" {
+			threadKey = involved.Namespace + "/" + deployment
+			rootID = c.deployThreads.rootFor(threadKey)
+		}
+	}
+
+	corrID, postID := c.notifyThreaded(mm, client, message, e, rootID)
+	if threadKey != "" && rootID == "" && postID != "" {
+		c.deployThreads.open(threadKey, postID)
+	}
+
+	e.CorrelationID = corrID
+	c.runRules(e)
+}
+
+// eventReplayWindow has replayRecentWarningEvents post startup Warning
+// events whose LastTimestamp/EventTime falls within this long of Run()
+// starting, so DeployBot surfaces problems that began before it did
+// instead of silently dropping them the way drainInitialQueue's discard
+// does for every other kind. 0 (the default) replays nothing. Overridden
+// via SetEventReplayWindow before Start().
+var eventReplayWindow time.Duration
+
+// SetEventReplayWindow overrides eventReplayWindow.
+func SetEventReplayWindow(d time.Duration) {
+	eventReplayWindow = d
+}
+
+// replayRecentWarningEvents posts Warning events still in this controller's
+// cache - populated by the initial List that drainInitialQueue otherwise
+// discards unprocessed - whose timestamp falls within eventReplayWindow of
+// now. Deduped against incident.DefaultLog by namespace/name/reason and
+// the rendered notification message, so an event this process has already
+// posted isn't posted again. Only meaningful for the Event controller; callers gate on
+// c.kind == "Event".
+func (c *Controller) replayRecentWarningEvents() {
+	cutoff := time.Now().Add(-eventReplayWindow)
+	for _, obj := range c.informer.GetIndexer().List() {
+		event, ok := obj.(*api_v1.Event)
+		if !ok || event.Type != api_v1.EventTypeWarning {
+			continue
+		}
+		eventTime := event.LastTimestamp.Time
+		if eventTime.IsZero() {
+			eventTime = event.EventTime.Time
+		}
+		if eventTime.Before(cutoff) {
+			continue
+		}
+
+		involved := event.InvolvedObject
+		// runRules records the rendered message, not the raw event.Message,
+		// so render it the same way notifyWarningEvent does to compare.
+		message := renderMessage("pod.crash", struct{ Kind, Namespace, Name, Reason, Message string }{involved.Kind, involved.Namespace, involved.Name, event.Reason, event.Message},
+			fmt.Sprintf("DeployBot - WARNING: %s %s/%s - %s: %s", involved.Kind, involved.Namespace, involved.Name, event.Reason, event.Message))
+		if alreadyRecorded(involved.Namespace, involved.Name, event.Reason, message) {
+			continue
+		}
+
+		c.notifyWarningEvent(event)
+	}
+}
+
+// alreadyRecorded reports whether incident.DefaultLog already has a record
+// matching namespace/name/reason/message, from either a previous replay or
+// this same event having been processed live already.
+func alreadyRecorded(namespace, name, reason, message string) bool {
+	for _, r := range incident.DefaultLog.Find(name) {
+		if r.Namespace == namespace && r.Reason == reason && r.Message == message {
+			return true
+		}
+	}
+	return false
+}
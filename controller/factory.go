@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/informers/internalinterfaces"
+	"k8s.io/client-go/tools/cache"
+)
+
+// sharedFactory is the one SharedInformerFactory every typed-client
+// controller built by Start() registers its informer into - one per
+// cluster, which today means exactly one, since Start() only ever talks to
+// a single kubeClient. Before this, every newController* built its own
+// cache.SharedIndexInformer by hand; if two controllers ever watched the
+// same Kind, each would open its own List+Watch against the API server and
+// keep its own copy of the cache. Routing construction through
+// newSharedIndexInformer instead means a second watcher for a Kind that's
+// already registered reuses the first one's informer rather than
+// duplicating it.
+//
+// This does NOT use the factory's typed accessors (e.g.
+// sharedFactory.Apps().V1().Deployments()): those all share one
+// factory-wide TweakListOptionsFunc, which can't express this package's
+// per-Kind fieldSelectors override (SetFieldSelectors). Each controller
+// keeps building its own cache.ListWatch via withFieldSelector exactly as
+// before; newSharedIndexInformer only changes who owns the resulting
+// informer's lifecycle and cache.
+var sharedFactory informers.SharedInformerFactory
+
+// newSharedIndexInformer registers newFunc into sharedFactory under obj's
+// type (e.g. *api_v1.Pod), returning the informer already registered for
+// that type instead of calling newFunc again if one exists. Only
+// controllers built against a kubernetes.Interface clientset go through
+// here - the OpenShift and operator-configured dynamic watches use the
+// dynamic client instead and keep building their informers directly, since
+// SharedInformerFactory has no dynamic/unstructured equivalent in this
+// client-go version.
+//
+// Note: this relies on nothing registering two controllers for the same
+// Kind and then calling Run() on both - this client-go version's
+// SharedIndexInformer.Run has no guard against being started twice, so the
+// second Run would open a second reflector against the same informer's
+// store. That's not a new risk this change introduces (every Kind here
+// still maps to exactly one controller), just one it doesn't yet solve.
+func newSharedIndexInformer(obj runtime.Object, newFunc internalinterfaces.NewInformerFunc) cache.SharedIndexInformer {
+	return sharedFactory.InformerFor(obj, newFunc)
+}
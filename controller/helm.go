@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"DeployBot/rules"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// helmReleaseSecretType is the Secret type Helm 3 uses for its storage
+// driver; every release revision gets its own Secret of this type named
+// sh.helm.release.v1.<release>.v<revision>.
+const helmReleaseSecretType = "helm.sh/release.v1"
+
+// isHelmReleaseSecret reports whether secret holds a Helm 3 release - by
+// Type when set, falling back to the well-known name prefix since some
+// older Helm 3 clients left Type unset.
+func isHelmReleaseSecret(secret *api_v1.Secret) bool {
+	if string(secret.Type) == helmReleaseSecretType {
+		return true
+	}
+	return strings.HasPrefix(secret.Name, "sh.helm.release.v1.")
+}
+
+// helmRelease is the subset of Helm 3's release.Release this package cares
+// about. Helm's storage driver serializes the full release as JSON before
+// gzip+base64 encoding it into the Secret, so no protobuf or helm package
+// needs to be vendored to read it back.
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// decodeHelmRelease reverses Helm's storage encoding: base64, then gzip,
+// then JSON.
+func decodeHelmRelease(data []byte) (helmRelease, error) {
+	var rel helmRelease
+
+	raw, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return rel, fmt.Errorf("base64 decode: %v", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return rel, fmt.Errorf("gzip reader: %v", err)
+	}
+	defer gz.Close()
+
+	if err := json.NewDecoder(gz).Decode(&rel); err != nil {
+		return rel, fmt.Errorf("decode release json: %v", err)
+	}
+	return rel, nil
+}
+
+// processHelmReleaseSecret posts "chart X upgraded from A to B" once a
+// release's chart version changes, giving chart-level visibility on top of
+// the raw Deployment/ConfigMap/Secret events a chart's templates render.
+func (c *Controller) processHelmReleaseSecret(secret *api_v1.Secret) error {
+	data, ok := secret.Data["release"]
+	if !ok {
+		return nil
+	}
+	rel, err := decodeHelmRelease(data)
+	if err != nil {
+		c.logger.WithError(err).Warnf("helm: couldn't decode release secret %s/%s", secret.Namespace, secret.Name)
+		return nil
+	}
+	if rel.Info.Status != "deployed" && rel.Info.Status != "superseded" {
+		return nil
+	}
+
+	key := rel.Namespace + "/" + rel.Name
+	previous, seen := c.helmCharts[key]
+	c.helmCharts[key] = rel.Chart.Metadata.Version
+	if !seen || previous == rel.Chart.Metadata.Version {
+		return nil
+	}
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+	message := fmt.Sprintf("DeployBot - chart %s upgraded from %s to %s in namespace %s (release %s, revision %d)",
+		rel.Chart.Metadata.Name, previous, rel.Chart.Metadata.Version, rel.Namespace, rel.Name, rel.Version)
+	e := rules.Event{Namespace: rel.Namespace, Kind: "HelmRelease", Name: rel.Name, Reason: "Upgraded", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
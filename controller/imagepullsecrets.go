@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"DeployBot/rules"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// newControllerServiceAccount watches core/v1 ServiceAccounts and alerts
+// when one references an imagePullSecrets Secret that doesn't exist in its
+// namespace - the same misconfiguration that otherwise isn't caught until
+// the next rollout's Pods fail with ImagePullBackOff.
+func newControllerServiceAccount(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ServiceAccounts(meta_v1.NamespaceAll).List(withFieldSelector("ServiceAccount", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ServiceAccounts(meta_v1.NamespaceAll).Watch(withFieldSelector("ServiceAccount", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.ServiceAccount{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.ServiceAccount{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if sa, ok := obj.(*api_v1.ServiceAccount); ok && !nsFilter.Allowed(sa.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(old, new interface{}) { enqueue(new) },
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+	})
+
+	return &Controller{
+		logger:            logrus.WithField("pkg", "kubewatch-serviceaccount"),
+		clientset:         client,
+		informer:          informer,
+		queue:             queue,
+		rules:             &rules.Engine{},
+		dispatcher:        rules.NewDispatcher(),
+		nsFilter:          nsFilter,
+		kind:              "ServiceAccount",
+		pullSecretAlerted: make(map[string]bool),
+	}
+}
+
+// processServiceAccountItem alerts once per missing imagePullSecrets Secret
+// referenced by a ServiceAccount, clearing the alert once the Secret shows
+// up (created late, or the ServiceAccount edited to drop the reference).
+func (c *Controller) processServiceAccountItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		delete(c.pullSecretAlerted, key)
+		return nil
+	}
+
+	sa, ok := Obj.(*api_v1.ServiceAccount)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	mm := sharedMM.WithChannel("DevopsBot")
+
+	present := map[string]bool{}
+	for _, ref := range sa.ImagePullSecrets {
+		alertKey := key + "/" + ref.Name
+		present[alertKey] = true
+
+		_, err := c.clientset.CoreV1().Secrets(sa.Namespace).Get(ref.Name, meta_v1.GetOptions{})
+		if err == nil {
+			delete(c.pullSecretAlerted, alertKey)
+			continue
+		}
+		if c.pullSecretAlerted[alertKey] {
+			continue
+		}
+		c.pullSecretAlerted[alertKey] = true
+
+		client := mm.GetClient()
+		message := fmt.Sprintf("DeployBot - ALERT: ServiceAccount %s/%s references imagePullSecrets %q, which doesn't exist - rollouts using it will fail with ImagePullBackOff", sa.Namespace, sa.Name, ref.Name)
+		e := rules.Event{Namespace: sa.Namespace, Kind: "ServiceAccount", Name: sa.Name, Reason: "ImagePullSecretMissing", Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+	}
+
+	for alertKey := range c.pullSecretAlerted {
+		if strings.HasPrefix(alertKey, key+"/") && !present[alertKey] {
+			delete(c.pullSecretAlerted, alertKey)
+		}
+	}
+	return nil
+}
+
+// registryCredentialCheckInterval is how often checkRegistryCredentials
+// re-validates every currently-referenced imagePullSecrets Secret's
+// registry credentials. Overridden via SetRegistryCredentialCheckInterval
+// before Start(); 0 (the default) disables the check entirely, since it
+// makes an outbound HTTPS request per distinct registry and not every
+// install wants that.
+var registryCredentialCheckInterval time.Duration
+
+// SetRegistryCredentialCheckInterval overrides registryCredentialCheckInterval.
+func SetRegistryCredentialCheckInterval(d time.Duration) {
+	registryCredentialCheckInterval = d
+}
+
+// registryAuthAlerted tracks which namespace/Secret/registry combinations
+// checkRegistryCredentials has already alerted on, so a registry that's
+// still down on the next tick doesn't re-alert every interval.
+var registryAuthAlerted = map[string]bool{}
+
+// runRegistryCredentialChecks ticks every registryCredentialCheckInterval,
+// re-validating the registry credentials in every imagePullSecrets Secret
+// referenced by a ServiceAccount in saController's cache, until stopCh
+// closes. A no-op if registryCredentialCheckInterval is 0.
+func runRegistryCredentialChecks(saController *Controller, stopCh <-chan struct{}) {
+	if registryCredentialCheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(registryCredentialCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			checkRegistryCredentials(saController)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// checkRegistryCredentials validates the registry credentials of every
+// distinct imagePullSecrets Secret currently referenced by a ServiceAccount
+// in saController's informer cache, alerting once per Secret/registry pair
+// whose credentials are rejected. A registry this bot simply can't reach
+// (DNS failure, network timeout, private/air-gapped registry) is skipped
+// rather than alerted on, since that's not the failure mode this check is
+// for - only credentials the registry itself rejects are.
+func checkRegistryCredentials(saController *Controller) {
+	mm := sharedMM.WithChannel("DevopsBot")
+
+	checked := map[string]bool{}
+	for _, obj := range saController.informer.GetIndexer().List() {
+		sa, ok := obj.(*api_v1.ServiceAccount)
+		if !ok {
+			continue
+		}
+		for _, ref := range sa.ImagePullSecrets {
+			secretKey := sa.Namespace + "/" + ref.Name
+			if checked[secretKey] {
+				continue
+			}
+			checked[secretKey] = true
+
+			secret, err := saController.clientset.CoreV1().Secrets(sa.Namespace).Get(ref.Name, meta_v1.GetOptions{})
+			if err != nil {
+				continue
+			}
+
+			for registry, creds := range registryCredentials(secret) {
+				alertKey := secretKey + "@" + registry
+				ok, err := validateRegistryAuth(registry, creds)
+				if err != nil {
+					continue
+				}
+				if ok {
+					delete(registryAuthAlerted, alertKey)
+					continue
+				}
+				if registryAuthAlerted[alertKey] {
+					continue
+				}
+				registryAuthAlerted[alertKey] = true
+
+				client := mm.GetClient()
+				message := fmt.Sprintf("DeployBot - ALERT: imagePullSecrets %s/%s's credentials for registry %s were rejected - the next rollout pulling from it will fail with ImagePullBackOff", sa.Namespace, ref.Name, registry)
+				e := rules.Event{Namespace: sa.Namespace, Kind: "Secret", Name: ref.Name, Reason: "RegistryAuthRejected", Message: message}
+				e.CorrelationID = saController.notify(mm, client, message, e)
+				saController.runRules(e)
+			}
+		}
+	}
+}
+
+// registryCredential is one registry host's username/password decoded out
+// of a dockerconfigjson/dockercfg Secret.
+type registryCredential struct {
+	Username string
+	Password string
+}
+
+// registryCredentials decodes every registry host a dockerconfigjson or
+// legacy dockercfg Secret has credentials for. Secrets of any other type
+// return no credentials - there's nothing to validate.
+func registryCredentials(secret *api_v1.Secret) map[string]registryCredential {
+	switch secret.Type {
+	case api_v1.SecretTypeDockerConfigJson:
+		return parseDockerConfigJSON(secret.Data[api_v1.DockerConfigJsonKey])
+	case api_v1.SecretTypeDockercfg:
+		return parseDockerConfigJSON(secret.Data[api_v1.DockerConfigKey])
+	default:
+		return nil
+	}
+}
+
+// parseDockerConfigJSON reads the {"auths": {"registry": {...}}} structure
+// shared by both ~/.docker/config.json and the legacy ~/.dockercfg formats
+// - dockercfg is just this same per-registry map without the "auths"
+// wrapper, so a Secret with no "auths" key is retried as that shape too.
+func parseDockerConfigJSON(data []byte) map[string]registryCredential {
+	var wrapped struct {
+		Auths map[string]dockerAuthEntry `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && len(wrapped.Auths) > 0 {
+		return decodeDockerAuths(wrapped.Auths)
+	}
+
+	var bare map[string]dockerAuthEntry
+	if err := json.Unmarshal(data, &bare); err != nil {
+		return nil
+	}
+	return decodeDockerAuths(bare)
+}
+
+// dockerAuthEntry is one registry's entry in a docker config file: either
+// a plain Username/Password or a combined base64("user:pass") Auth field.
+type dockerAuthEntry struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+func decodeDockerAuths(auths map[string]dockerAuthEntry) map[string]registryCredential {
+	out := make(map[string]registryCredential, len(auths))
+	for registry, entry := range auths {
+		username, password := entry.Username, entry.Password
+		if username == "" && entry.Auth != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(entry.Auth); err == nil {
+				if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+					username, password = parts[0], parts[1]
+				}
+			}
+		}
+		if username != "" {
+			out[registry] = registryCredential{Username: username, Password: password}
+		}
+	}
+	return out
+}
+
+// registryAuthCheckTimeout bounds how long validateRegistryAuth waits for a
+// registry to respond, so one unreachable registry can't stall the whole
+// periodic check.
+const registryAuthCheckTimeout = 5 * time.Second
+
+// validateRegistryAuth reports whether registry accepts creds, by hitting
+// its v2 API root with HTTP Basic Auth - the same request `docker login`
+// makes. A non-nil error means the registry couldn't be reached at all
+// (network, DNS, TLS) and the result should be treated as inconclusive,
+// not a credential failure.
+func validateRegistryAuth(registry string, creds registryCredential) (bool, error) {
+	host := registry
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(host, "/")+"/v2/", nil)
+	if err != nil {
+		return false, err
+	}
+	req.SetBasicAuth(creds.Username, creds.Password)
+
+	client := &http.Client{Timeout: registryAuthCheckTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return false, nil
+	}
+	return true, nil
+}
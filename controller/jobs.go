@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// jobOutcome summarizes why a Job stopped, pulled from its status
+// conditions and (on failure) the termination message of its last pod.
+type jobOutcome struct {
+	namespace string
+	name      string
+	succeeded bool
+	reason    string
+	message   string
+	duration  time.Duration
+}
+
+// jobMeta reports whether a Job has reached a terminal state (Complete or
+// Failed) and, if so, summarizes the outcome. ok is false while the Job is
+// still running.
+func jobMeta(clientset kubernetes.Interface, job *batchv1.Job) (outcome jobOutcome, ok bool) {
+	var cond *batchv1.JobCondition
+	for i := range job.Status.Conditions {
+		c := job.Status.Conditions[i]
+		if c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed {
+			cond = &c
+			break
+		}
+	}
+	if cond == nil {
+		return jobOutcome{}, false
+	}
+
+	outcome = jobOutcome{
+		namespace: job.Namespace,
+		name:      job.Name,
+		succeeded: cond.Type == batchv1.JobComplete,
+		reason:    cond.Reason,
+		message:   cond.Message,
+	}
+	if job.Status.StartTime != nil && job.Status.CompletionTime != nil {
+		outcome.duration = job.Status.CompletionTime.Sub(job.Status.StartTime.Time)
+	}
+	if !outcome.succeeded && outcome.message == "" {
+		outcome.message = lastPodTerminationMessage(clientset, job)
+	}
+	return outcome, true
+}
+
+// lastPodTerminationMessage looks up the Job's pods and returns the
+// termination message of the most recently finished container, used to
+// flesh out a failure notification when the Job condition itself carries
+// no message.
+func lastPodTerminationMessage(clientset kubernetes.Interface, job *batchv1.Job) string {
+	pods, err := clientset.CoreV1().Pods(job.Namespace).List(meta_v1.ListOptions{
+		LabelSelector: "job-name=" + job.Name,
+	})
+	if err != nil || len(pods.Items) == 0 {
+		return ""
+	}
+
+	pod := pods.Items[len(pods.Items)-1]
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.Message != "" {
+			return status.State.Terminated.Message
+		}
+	}
+	return ""
+}
+
+// newControllerJob watches Jobs and notifies the channel when one reaches
+// a terminal state, with the same queue/informer shape as the Deployment
+// and Pod controllers.
+func newControllerJob(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.BatchV1().Jobs(meta_v1.NamespaceAll).List(withFieldSelector("Job", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.BatchV1().Jobs(meta_v1.NamespaceAll).Watch(withFieldSelector("Job", options))
+		},
+	}
+	informer := newSharedIndexInformer(&batchv1.Job{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &batchv1.Job{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if job, ok := obj.(*batchv1.Job); ok && !nsFilter.Allowed(job.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(old, new interface{}) {
+			// A Job already terminal in old was reported when it got there;
+			// later updates (resyncs, label edits) aren't a new outcome.
+			if oldJob, ok := old.(*batchv1.Job); ok {
+				for _, c := range oldJob.Status.Conditions {
+					if c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed {
+						return
+					}
+				}
+			}
+			enqueue(new)
+		},
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-job"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       "Job",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+	}
+}
+
+// processJobItem notifies the channel the first time a Job's status shows
+// it reached a terminal state. Jobs still Active are ignored; they'll be
+// re-queued by a later Update once they finish.
+func (c *Controller) processJobItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		return nil
+	}
+
+	job, ok := Obj.(*batchv1.Job)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	outcome, done := jobMeta(c.clientset, job)
+	if !done {
+		return nil
+	}
+
+	mm := sharedMM.WithChannel("DevopsBot")
+	client := mm.GetClient()
+
+	var message string
+	reason := "Completed"
+	if outcome.succeeded {
+		message = fmt.Sprintf("DeployBot - Job %s completed in %s", outcome.name, outcome.duration)
+	} else {
+		reason = "Failed"
+		message = fmt.Sprintf("DeployBot - Job %s failed after %s: %s", outcome.name, outcome.duration, outcome.message)
+	}
+	e := rules.Event{
+		Namespace: outcome.namespace,
+		Kind:      "Job",
+		Name:      outcome.name,
+		Reason:    reason,
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
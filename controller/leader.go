@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// leaderElectionConfigMap names the lock object every DeployBot
+	// replica contends over.
+	leaderElectionConfigMap = "deploybot-leader"
+	leaseDuration           = 15 * time.Second
+	renewDeadline           = 10 * time.Second
+	retryPeriod             = 2 * time.Second
+)
+
+// leading is 1 while this replica holds the leader lock, 0 otherwise. It
+// starts at 0 so a replica that hasn't won an election yet (or doesn't run
+// one at all - see Start()) stays a standby until told otherwise.
+var leading int32
+
+// IsLeader reports whether this replica currently holds the leader lock.
+// Controllers consult it in runWorker to decide whether to drain their
+// workqueue and post to Mattermost; every replica still runs its
+// informers regardless, so a standby's caches are warm and it can take
+// over instantly if it wins the next election.
+func IsLeader() bool {
+	return atomic.LoadInt32(&leading) == 1
+}
+
+// setLeading is a test seam and fallback setter for the single-replica case
+// where leader election isn't configured at all.
+func setLeading(v bool) {
+	if v {
+		atomic.StoreInt32(&leading, 1)
+	} else {
+		atomic.StoreInt32(&leading, 0)
+	}
+}
+
+// runLeaderElection contends for leadership of namespace's lock as
+// identity until stopCh closes, flipping IsLeader() as it's won and lost.
+// True Lease (coordination.k8s.io) locks aren't vendored in this
+// client-go, so this uses the older ConfigMap-based resourcelock instead -
+// same acquire/renew/release semantics, just backed by a ConfigMap
+// annotation rather than a Lease object.
+func runLeaderElection(client kubernetes.Interface, namespace, identity string, stopCh <-chan struct{}) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events(namespace)})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, api_v1.EventSource{Component: "deploybot"})
+
+	lock := &resourcelock.ConfigMapLock{
+		ConfigMapMeta: meta_v1.ObjectMeta{Name: leaderElectionConfigMap, Namespace: namespace},
+		Client:        client.CoreV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity:      identity,
+			EventRecorder: recorder,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				logrus.WithField("identity", identity).Info("acquired DeployBot leadership")
+				setLeading(true)
+			},
+			OnStoppedLeading: func() {
+				logrus.WithField("identity", identity).Warn("lost DeployBot leadership")
+				setLeading(false)
+			},
+		},
+	})
+	if err != nil {
+		logrus.WithError(err).Error("leader election setup failed, running as leader unconditionally")
+		setLeading(true)
+		return
+	}
+
+	// elector.Run() blocks for exactly one acquire+hold+lose cycle and
+	// takes no stopCh of its own in this client-go version, so keep
+	// re-entering it until our own stopCh closes.
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+			elector.Run()
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// servicePorts renders a Service's ports the same way on every message, so
+// an Update handler can tell whether they actually changed.
+func servicePorts(svc *api_v1.Service) string {
+	parts := make([]string, 0, len(svc.Spec.Ports))
+	for _, p := range svc.Spec.Ports {
+		parts = append(parts, fmt.Sprintf("%d/%s", p.Port, p.Protocol))
+	}
+	return strings.Join(parts, ",")
+}
+
+// serviceHosts renders the hostnames/IPs a Service is externally reachable
+// at - its LoadBalancer ingress points, if any.
+func serviceHosts(svc *api_v1.Service) string {
+	parts := make([]string, 0, len(svc.Status.LoadBalancer.Ingress))
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" {
+			parts = append(parts, lb.Hostname)
+		} else if lb.IP != "" {
+			parts = append(parts, lb.IP)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// ingressHosts renders the hostnames an Ingress routes, so an Update
+// handler can tell whether they changed.
+func ingressHosts(ing *extv1beta1.Ingress) string {
+	parts := make([]string, 0, len(ing.Spec.Rules))
+	for _, rule := range ing.Spec.Rules {
+		parts = append(parts, rule.Host)
+	}
+	return strings.Join(parts, ",")
+}
+
+func newControllerService(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Services(meta_v1.NamespaceAll).List(withFieldSelector("Service", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Services(meta_v1.NamespaceAll).Watch(withFieldSelector("Service", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.Service{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.Service{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if svc, ok := obj.(*api_v1.Service); ok && !nsFilter.Allowed(svc.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		DeleteFunc: func(obj interface{}) {
+			if svc, ok := obj.(*api_v1.Service); ok && !nsFilter.Allowed(svc.Namespace) {
+				return
+			}
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			oldSvc, ok1 := old.(*api_v1.Service)
+			newSvc, ok2 := new.(*api_v1.Service)
+			if !ok1 || !ok2 {
+				return
+			}
+			if servicePorts(oldSvc) == servicePorts(newSvc) && serviceHosts(oldSvc) == serviceHosts(newSvc) {
+				return
+			}
+			enqueue(new)
+		},
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-service"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       "Service",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+	}
+}
+
+func newControllerIngress(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.ExtensionsV1beta1().Ingresses(meta_v1.NamespaceAll).List(withFieldSelector("Ingress", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.ExtensionsV1beta1().Ingresses(meta_v1.NamespaceAll).Watch(withFieldSelector("Ingress", options))
+		},
+	}
+	informer := newSharedIndexInformer(&extv1beta1.Ingress{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &extv1beta1.Ingress{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if ing, ok := obj.(*extv1beta1.Ingress); ok && !nsFilter.Allowed(ing.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		DeleteFunc: func(obj interface{}) {
+			if ing, ok := obj.(*extv1beta1.Ingress); ok && !nsFilter.Allowed(ing.Namespace) {
+				return
+			}
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			oldIng, ok1 := old.(*extv1beta1.Ingress)
+			newIng, ok2 := new.(*extv1beta1.Ingress)
+			if !ok1 || !ok2 {
+				return
+			}
+			if ingressHosts(oldIng) == ingressHosts(newIng) {
+				return
+			}
+			enqueue(new)
+		},
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-ingress"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       "Ingress",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+	}
+}
+
+func (c *Controller) processServiceItem(key string, Obj interface{}, exists bool) error {
+	mm := sharedMM.WithChannel("DevopsBot")
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: "Service", Name: key, Reason: "Deleted", Message: "Deleted Service in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted Service in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	svc, ok := Obj.(*api_v1.Service)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	message := fmt.Sprintf("DeployBot - Service %s changed - ports: [%s] hosts: [%s]", svc.Name, servicePorts(svc), serviceHosts(svc))
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: svc.Namespace,
+		Kind:      "Service",
+		Name:      svc.Name,
+		Reason:    "Changed",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
+
+func (c *Controller) processIngressItem(key string, Obj interface{}, exists bool) error {
+	mm := sharedMM.WithChannel("DevopsBot")
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: "Ingress", Name: key, Reason: "Deleted", Message: "Deleted Ingress in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted Ingress in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	ing, ok := Obj.(*extv1beta1.Ingress)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	message := fmt.Sprintf("DeployBot - Ingress %s changed - hosts: [%s]", ing.Name, ingressHosts(ing))
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: ing.Namespace,
+		Kind:      "Ingress",
+		Name:      ing.Name,
+		Reason:    "Changed",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"DeployBot/enrich"
+	"DeployBot/mattermostapi"
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"DeployBot/upgradewatch"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mattermost/mattermost-server/model"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// nodeDebounce is how long a node's condition must hold steady before an
+// alert/recovery message fires, so a node flapping between Ready and
+// NotReady doesn't spam the channel on every transition.
+const nodeDebounce = 2 * time.Minute
+
+// nodeHealthState is what nodeHealth remembers about a node between
+// informer callbacks, to debounce and to detect recoveries.
+type nodeHealthState struct {
+	unhealthy  bool
+	since      time.Time
+	lastAlerts map[api_v1.NodeConditionType]bool
+}
+
+// nodeHealth debounces Node condition flapping across informer callbacks.
+// It's a small amount of state alongside the Controller rather than on it,
+// since no other watched kind needs this.
+type nodeHealth struct {
+	mu       sync.Mutex
+	states   map[string]*nodeHealthState
+	cordoned map[string]bool
+	versions map[string]string
+}
+
+func newNodeHealth() *nodeHealth {
+	return &nodeHealth{
+		states:   make(map[string]*nodeHealthState),
+		cordoned: make(map[string]bool),
+		versions: make(map[string]string),
+	}
+}
+
+// observeCordon records node's current Unschedulable state and reports
+// whether it just changed, so processNodeItem only narrates cordon/uncordon
+// transitions rather than every resync.
+func (h *nodeHealth) observeCordon(name string, unschedulable bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cordoned[name] == unschedulable {
+		return false
+	}
+	h.cordoned[name] = unschedulable
+	return true
+}
+
+// observeVersion records node's kubelet version and returns it alongside
+// the version most other known nodes are running, so the caller can tell
+// whether this node is the odd one out during a rolling upgrade.
+func (h *nodeHealth) observeVersion(name, version string) (majority string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.versions[name] = version
+
+	counts := make(map[string]int)
+	for _, v := range h.versions {
+		counts[v]++
+	}
+	var best string
+	var bestCount int
+	for v, n := range counts {
+		if n > bestCount {
+			best, bestCount = v, n
+		}
+	}
+	return best
+}
+
+// badConditions returns the unhealthy conditions currently set on node -
+// NotReady, MemoryPressure, DiskPressure - keyed by condition type.
+func badConditions(node *api_v1.Node) map[api_v1.NodeConditionType]bool {
+	bad := make(map[api_v1.NodeConditionType]bool)
+	for _, cond := range node.Status.Conditions {
+		switch cond.Type {
+		case api_v1.NodeReady:
+			if cond.Status != api_v1.ConditionTrue {
+				bad[api_v1.NodeReady] = true
+			}
+		case api_v1.NodeMemoryPressure, api_v1.NodeDiskPressure:
+			if cond.Status == api_v1.ConditionTrue {
+				bad[cond.Type] = true
+			}
+		}
+	}
+	return bad
+}
+
+// observe records the current bad conditions for a node and returns the
+// conditions that should actually alert/recover right now, after the
+// nodeDebounce window has passed since the state last changed.
+func (h *nodeHealth) observe(name string, bad map[api_v1.NodeConditionType]bool) (alert, recovered map[api_v1.NodeConditionType]bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	alert = make(map[api_v1.NodeConditionType]bool)
+	recovered = make(map[api_v1.NodeConditionType]bool)
+
+	state, ok := h.states[name]
+	if !ok {
+		state = &nodeHealthState{lastAlerts: make(map[api_v1.NodeConditionType]bool)}
+		h.states[name] = state
+	}
+
+	now := time.Now()
+	wasUnhealthy := len(bad) > 0
+	if wasUnhealthy != state.unhealthy {
+		state.unhealthy = wasUnhealthy
+		state.since = now
+	}
+
+	stable := now.Sub(state.since) >= nodeDebounce
+	if !stable {
+		return alert, recovered
+	}
+
+	for cond := range bad {
+		if !state.lastAlerts[cond] {
+			alert[cond] = true
+			state.lastAlerts[cond] = true
+		}
+	}
+	for cond := range state.lastAlerts {
+		if !bad[cond] {
+			recovered[cond] = true
+			delete(state.lastAlerts, cond)
+		}
+	}
+	return alert, recovered
+}
+
+func newControllerNode(client kubernetes.Interface) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Nodes().List(withFieldSelector("Node", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Nodes().Watch(withFieldSelector("Node", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.Node{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.Node{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(old, new interface{}) { enqueue(new) },
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-node"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		kind:       "Node",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		nodeHealth: newNodeHealth(),
+	}
+}
+
+func (c *Controller) processNodeItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		return nil
+	}
+
+	node, ok := Obj.(*api_v1.Node)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+
+	if upgradewatch.Active() {
+		c.reportCordonAndSkew(mm, client, node)
+	}
+
+	alert, recovered := c.nodeHealth.observe(node.Name, badConditions(node))
+	if len(alert) == 0 && len(recovered) == 0 {
+		return nil
+	}
+
+	var nodeInfo string
+	if eventEnrichmentEnabled {
+		nodeInfo = enrich.Default.Enrich(node).String()
+	}
+
+	for cond := range alert {
+		fallback := fmt.Sprintf("DeployBot - ALERT: node %s is %s", node.Name, cond)
+		if nodeInfo != "" {
+			fallback += fmt.Sprintf(" [%s]", nodeInfo)
+		}
+		message := renderMessage("node.alert", struct{ Name, Condition, Info string }{node.Name, string(cond), nodeInfo}, fallback)
+		e := rules.Event{Kind: "Node", Name: node.Name, Reason: string(cond), Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+	}
+	for cond := range recovered {
+		fallback := fmt.Sprintf("DeployBot - RECOVERED: node %s is no longer %s", node.Name, cond)
+		if nodeInfo != "" {
+			fallback += fmt.Sprintf(" [%s]", nodeInfo)
+		}
+		message := renderMessage("node.recovered", struct{ Name, Condition, Info string }{node.Name, string(cond), nodeInfo}, fallback)
+		e := rules.Event{Kind: "Node", Name: node.Name, Reason: "Recovered" + string(cond), Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+	}
+	return nil
+}
+
+// reportCordonAndSkew narrates every node cordon/uncordon and kubelet
+// version skew while an upgrade-watch window is active - noise this package
+// normally debounces away, but exactly what an upgrade needs reported live.
+func (c *Controller) reportCordonAndSkew(mm *mattermostapi.MatterMost, client *model.Client, node *api_v1.Node) {
+	if changed := c.nodeHealth.observeCordon(node.Name, node.Spec.Unschedulable); changed {
+		upgradewatch.RecordCordon(node.Name, node.Spec.Unschedulable)
+		state := "cordoned"
+		if !node.Spec.Unschedulable {
+			state = "uncordoned"
+		}
+		message := fmt.Sprintf("DeployBot - upgrade-watch: node %s %s", node.Name, state)
+		e := rules.Event{Kind: "Node", Name: node.Name, Reason: "Cordon", Message: message}
+		e.CorrelationID = c.notify(mm, client, message, e)
+		c.runRules(e)
+	}
+
+	version := node.Status.NodeInfo.KubeletVersion
+	if version == "" {
+		return
+	}
+	majority := c.nodeHealth.observeVersion(node.Name, version)
+	if majority == "" || version == majority {
+		return
+	}
+	upgradewatch.RecordVersionSkew(node.Name, version, majority)
+	message := fmt.Sprintf("DeployBot - upgrade-watch: node %s is running kubelet %s, cluster majority is %s", node.Name, version, majority)
+	e := rules.Event{Kind: "Node", Name: node.Name, Reason: "VersionSkew", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+}
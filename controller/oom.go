@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"DeployBot/enrich"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// oomEvent is one container OOMKilled transition detected between two
+// observations of a Pod.
+type oomEvent struct {
+	Container   string
+	MemoryLimit string
+	Deployment  string
+	Node        enrich.Info
+}
+
+// newOOMKills compares old and new Pod container statuses and returns an
+// oomEvent for every container whose LastTerminationState just became
+// OOMKilled - i.e. it wasn't already recorded as terminated at that same
+// timestamp in old, so a container that OOMs repeatedly is reported every
+// time, not just once.
+func newOOMKills(clientset kubernetes.Interface, old, new *api_v1.Pod) []oomEvent {
+	oldTerminatedAt := map[string]meta_v1.Time{}
+	for _, cs := range old.Status.ContainerStatuses {
+		if cs.LastTerminationState.Terminated != nil {
+			oldTerminatedAt[cs.Name] = cs.LastTerminationState.Terminated.StartedAt
+		}
+	}
+
+	var events []oomEvent
+	for _, cs := range new.Status.ContainerStatuses {
+		term := cs.LastTerminationState.Terminated
+		if term == nil || term.Reason != "OOMKilled" {
+			continue
+		}
+		if oldTerminatedAt[cs.Name] == term.StartedAt {
+			continue
+		}
+		events = append(events, oomEvent{
+			Container:   cs.Name,
+			MemoryLimit: containerMemoryLimit(new, cs.Name),
+			Deployment:  deploymentForPod(clientset, new),
+			Node:        nodeInfoForPod(clientset, new),
+		})
+	}
+	return events
+}
+
+// containerMemoryLimit returns name's configured memory limit from pod's
+// spec, or "none" if it has none set.
+func containerMemoryLimit(pod *api_v1.Pod, name string) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != name {
+			continue
+		}
+		if mem, ok := c.Resources.Limits[api_v1.ResourceMemory]; ok {
+			return mem.String()
+		}
+		return "none"
+	}
+	return "none"
+}
+
+// deploymentForPod walks pod's OwnerReferences up through its ReplicaSet to
+// find the owning Deployment's name, or "" if it isn't owned by one.
+func deploymentForPod(clientset kubernetes.Interface, pod *api_v1.Pod) string {
+	rsRef, ok := controllingOwner(pod.OwnerReferences)
+	if !ok || rsRef.Kind != "ReplicaSet" {
+		return ""
+	}
+	rs, err := clientset.AppsV1().ReplicaSets(pod.Namespace).Get(rsRef.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	depRef, ok := controllingOwner(rs.OwnerReferences)
+	if !ok || depRef.Kind != "Deployment" {
+		return ""
+	}
+	return depRef.Name
+}
+
+// deploymentForInvolvedObject resolves an Event's InvolvedObject up to its
+// owning Deployment the same way deploymentForPod does, for whichever of
+// Pod or ReplicaSet the event landed on - Kubernetes attaches Warning
+// events to either depending on what actually failed (e.g. a FailedCreate
+// event for a bad Pod template lands on the ReplicaSet, not a Pod).
+func deploymentForInvolvedObject(clientset kubernetes.Interface, involved api_v1.ObjectReference) string {
+	switch involved.Kind {
+	case "Pod":
+		pod, err := clientset.CoreV1().Pods(involved.Namespace).Get(involved.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		return deploymentForPod(clientset, pod)
+	case "ReplicaSet":
+		rs, err := clientset.AppsV1().ReplicaSets(involved.Namespace).Get(involved.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		depRef, ok := controllingOwner(rs.OwnerReferences)
+		if !ok || depRef.Kind != "Deployment" {
+			return ""
+		}
+		return depRef.Name
+	default:
+		return ""
+	}
+}
+
+// nodeInfoForPod looks up the Node a Pod landed on and runs it through
+// enrich.Default, returning a zero enrich.Info if enrichment is disabled,
+// the Pod hasn't been scheduled yet, or the Node Get fails - enrichment is
+// always best-effort, never worth failing the event over.
+func nodeInfoForPod(clientset kubernetes.Interface, pod *api_v1.Pod) enrich.Info {
+	if !eventEnrichmentEnabled || pod.Spec.NodeName == "" {
+		return enrich.Info{}
+	}
+	node, err := clientset.CoreV1().Nodes().Get(pod.Spec.NodeName, meta_v1.GetOptions{})
+	if err != nil {
+		return enrich.Info{}
+	}
+	return enrich.Default.Enrich(node)
+}
+
+// controllingOwner returns the controlling OwnerReference (Controller ==
+// true) if one is set, falling back to the first reference otherwise.
+func controllingOwner(refs []meta_v1.OwnerReference) (meta_v1.OwnerReference, bool) {
+	for _, r := range refs {
+		if r.Controller != nil && *r.Controller {
+			return r, true
+		}
+	}
+	if len(refs) > 0 {
+		return refs[0], true
+	}
+	return meta_v1.OwnerReference{}, false
+}
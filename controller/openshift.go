@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"DeployBot/mattermostapi"
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// This tree has no vendored OpenShift client-go (the generated
+// DeploymentConfig/Route/BuildConfig clientsets), so these watchers talk to
+// the OCP API groups through the plain Kubernetes dynamic client instead,
+// treating every object as unstructured.Unstructured. That's enough for
+// watching and rendering notifications; anything that needed typed access
+// (e.g. mutating one of these resources) would still need the real
+// clientset vendored.
+
+// dynamicResource names one arbitrary API group/version/resource that can
+// be watched through the dynamic client rather than a generated typed
+// clientset - used both for the hardcoded OCP resources below and for
+// operator-configured watches (see dynamicwatch.go).
+type dynamicResource struct {
+	GroupVersion string // e.g. "apps.openshift.io/v1"
+	Resource     string // plural, e.g. "deploymentconfigs"
+	Kind         string // matches Controller.kind, e.g. "DeploymentConfig"
+	Namespaced   bool
+}
+
+var (
+	deploymentConfigResource = dynamicResource{GroupVersion: "apps.openshift.io/v1", Resource: "deploymentconfigs", Kind: "DeploymentConfig", Namespaced: true}
+	routeResource            = dynamicResource{GroupVersion: "route.openshift.io/v1", Resource: "routes", Kind: "Route", Namespaced: true}
+	buildConfigResource      = dynamicResource{GroupVersion: "build.openshift.io/v1", Resource: "buildconfigs", Kind: "BuildConfig", Namespaced: true}
+)
+
+// dynamicResourceClient returns a dynamic client scoped to res's group,
+// version, and resource in namespace (ignored if res isn't Namespaced),
+// talking to the cluster config passed to Start(). A cluster that doesn't
+// have res's API group simply fails every List/Watch call on it (404) -
+// the controller logs and backs off like any other informer error, it
+// doesn't need to know in advance.
+func dynamicResourceClient(restConfig *rest.Config, res dynamicResource, namespace string) (dynamic.ResourceInterface, error) {
+	gv, err := schema.ParseGroupVersion(res.GroupVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	confCopy := *restConfig
+	confCopy.GroupVersion = &gv
+	confCopy.APIPath = "/apis"
+
+	client, err := dynamic.NewClient(&confCopy)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Namespaced {
+		namespace = ""
+	}
+	return client.Resource(&meta_v1.APIResource{Name: res.Resource, Namespaced: res.Namespaced}, namespace), nil
+}
+
+// newDynamicController builds a Controller that watches res through the
+// dynamic client and treats every object as unstructured.Unstructured. If
+// generic is true, processItem renders generic add/update/delete
+// notifications for it (see dynamicwatch.go); hardcoded OCP resources pass
+// false and keep their own field-aware processXItem.
+func newDynamicController(restConfig *rest.Config, res dynamicResource, nsFilter NamespaceFilter, generic bool) (*Controller, error) {
+	resourceClient, err := dynamicResourceClient(restConfig, res, meta_v1.NamespaceAll)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic watch: building %s client: %v", res.Kind, err)
+	}
+
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				return resourceClient.List(withFieldSelector(res.Kind, options))
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				return resourceClient.Watch(withFieldSelector(res.Kind, options))
+			},
+		},
+		&unstructured.Unstructured{},
+		resyncPeriod,
+		cache.Indexers{},
+	)
+
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+	enqueue := func(obj interface{}) {
+		if u, ok := obj.(*unstructured.Unstructured); ok && !nsFilter.Allowed(u.GetNamespace()) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		DeleteFunc: func(obj interface{}) {
+			if u, ok := obj.(*unstructured.Unstructured); ok && !nsFilter.Allowed(u.GetNamespace()) {
+				return
+			}
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			oldU, ok1 := old.(*unstructured.Unstructured)
+			newU, ok2 := new.(*unstructured.Unstructured)
+			if !ok1 || !ok2 {
+				return
+			}
+			if oldU.GetResourceVersion() == newU.GetResourceVersion() {
+				return
+			}
+			enqueue(new)
+		},
+	})
+
+	return &Controller{
+		logger:         logrus.WithField("pkg", "kubewatch-"+res.Kind),
+		informer:       informer,
+		queue:          queue,
+		rules:          &rules.Engine{},
+		dispatcher:     rules.NewDispatcher(),
+		nsFilter:       nsFilter,
+		kind:           res.Kind,
+		latency:        metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		genericDynamic: generic,
+	}, nil
+}
+
+func newControllerDeploymentConfig(restConfig *rest.Config, nsFilter NamespaceFilter) (*Controller, error) {
+	return newDynamicController(restConfig, deploymentConfigResource, nsFilter, false)
+}
+
+func newControllerRoute(restConfig *rest.Config, nsFilter NamespaceFilter) (*Controller, error) {
+	return newDynamicController(restConfig, routeResource, nsFilter, false)
+}
+
+func newControllerBuildConfig(restConfig *rest.Config, nsFilter NamespaceFilter) (*Controller, error) {
+	return newDynamicController(restConfig, buildConfigResource, nsFilter, false)
+}
+
+func openshiftMatterMost() *mattermostapi.MatterMost {
+	return sharedMM.WithChannel("DevopsBot")
+}
+
+func (c *Controller) processDeploymentConfigItem(key string, obj interface{}, exists bool) error {
+	mm := openshiftMatterMost()
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: "DeploymentConfig", Name: key, Reason: "Deleted", Message: "Deleted DeploymentConfig in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted DeploymentConfig in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	latestVersion, _ := unstructured.NestedInt64(u.Object, "status", "latestVersion")
+	replicas, _ := unstructured.NestedInt64(u.Object, "status", "replicas")
+	message := fmt.Sprintf("DeployBot - DeploymentConfig %s changed - latest version: %d, replicas: %d", u.GetName(), latestVersion, replicas)
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: u.GetNamespace(),
+		Kind:      "DeploymentConfig",
+		Name:      u.GetName(),
+		Reason:    "Changed",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
+
+func (c *Controller) processRouteItem(key string, obj interface{}, exists bool) error {
+	mm := openshiftMatterMost()
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: "Route", Name: key, Reason: "Deleted", Message: "Deleted Route in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted Route in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	host, _ := unstructured.NestedString(u.Object, "spec", "host")
+	message := fmt.Sprintf("DeployBot - Route %s changed - host: %s", u.GetName(), host)
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: u.GetNamespace(),
+		Kind:      "Route",
+		Name:      u.GetName(),
+		Reason:    "Changed",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
+
+func (c *Controller) processBuildConfigItem(key string, obj interface{}, exists bool) error {
+	mm := openshiftMatterMost()
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: "BuildConfig", Name: key, Reason: "Deleted", Message: "Deleted BuildConfig in OCP - " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted BuildConfig in OCP - "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	lastVersion, _ := unstructured.NestedInt64(u.Object, "status", "lastVersion")
+	message := fmt.Sprintf("DeployBot - BuildConfig %s changed - last build version: %d", u.GetName(), lastVersion)
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: u.GetNamespace(),
+		Kind:      "BuildConfig",
+		Name:      u.GetName(),
+		Reason:    "Changed",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
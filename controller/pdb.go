@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// pdbBlockedThreshold is how long a PodDisruptionBudget must sit at
+// disruptionsAllowed=0 before DeployBot alerts - long enough that a drain
+// or rollout briefly squeezing a PDB to zero doesn't page anyone, but short
+// enough to still catch an upgrade stalling on it.
+const pdbBlockedThreshold = 5 * time.Minute
+
+func newControllerPDB(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.PolicyV1beta1().PodDisruptionBudgets(meta_v1.NamespaceAll).List(withFieldSelector("PodDisruptionBudget", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.PolicyV1beta1().PodDisruptionBudgets(meta_v1.NamespaceAll).Watch(withFieldSelector("PodDisruptionBudget", options))
+		},
+	}
+	informer := newSharedIndexInformer(&policyv1beta1.PodDisruptionBudget{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &policyv1beta1.PodDisruptionBudget{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if pdb, ok := obj.(*policyv1beta1.PodDisruptionBudget); ok && !nsFilter.Allowed(pdb.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(old, new interface{}) { enqueue(new) },
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+	})
+
+	return &Controller{
+		logger:          logrus.WithField("pkg", "kubewatch-pdb"),
+		clientset:       client,
+		informer:        informer,
+		queue:           queue,
+		rules:           &rules.Engine{},
+		dispatcher:      rules.NewDispatcher(),
+		nsFilter:        nsFilter,
+		kind:            "PodDisruptionBudget",
+		latency:         metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		pdbBlockedSince: make(map[string]time.Time),
+		pdbAlerted:      make(map[string]bool),
+	}
+}
+
+// processPDBItem alerts once a PDB has blocked every voluntary disruption
+// (disruptionsAllowed=0) for longer than pdbBlockedThreshold - whether
+// that's a transient squeeze during a drain/rollout or a permanently
+// misconfigured budget, both stall upgrades the same way and silently.
+func (c *Controller) processPDBItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		delete(c.pdbBlockedSince, key)
+		delete(c.pdbAlerted, key)
+		return nil
+	}
+
+	pdb, ok := Obj.(*policyv1beta1.PodDisruptionBudget)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	mm := sharedMM.WithChannel("ops")
+
+	if pdb.Status.PodDisruptionsAllowed > 0 {
+		delete(c.pdbBlockedSince, key)
+		if c.pdbAlerted[key] {
+			c.pdbAlerted[key] = false
+			client := mm.GetClient()
+			message := fmt.Sprintf("DeployBot - RECOVERED: PodDisruptionBudget %s allows disruptions again (%d/%d healthy)", key, pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy)
+			e := rules.Event{Namespace: pdb.Namespace, Kind: "PodDisruptionBudget", Name: pdb.Name, Reason: "Recovered", Message: message}
+			e.CorrelationID = c.notify(mm, client, message, e)
+			c.runRules(e)
+		}
+		return nil
+	}
+
+	since, seen := c.pdbBlockedSince[key]
+	if !seen {
+		c.pdbBlockedSince[key] = time.Now()
+		return nil
+	}
+	if time.Since(since) < pdbBlockedThreshold || c.pdbAlerted[key] {
+		return nil
+	}
+	c.pdbAlerted[key] = true
+
+	client := mm.GetClient()
+	message := fmt.Sprintf("DeployBot - ALERT: PodDisruptionBudget %s has blocked all voluntary disruptions for over %s (%d/%d healthy, %d expected) - drains/rollouts against its pods will stall", key, pdbBlockedThreshold, pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy, pdb.Status.ExpectedPods)
+	e := rules.Event{Namespace: pdb.Namespace, Kind: "PodDisruptionBudget", Name: pdb.Name, Reason: "Blocked", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"DeployBot/lru"
+	"DeployBot/mattermostapi"
+	"DeployBot/resolution"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// maxOpenAlerts bounds how many notifications are remembered while waiting
+// for `!resolve`, so a long-running process doesn't grow memory without
+// bound; once full, the least recently used alert is evicted, and a later
+// Resolve for it reports that no open alert is tracked.
+const maxOpenAlerts = 2048
+
+// openAlert is what notifyThreaded remembers about a notification so
+// Resolve can close it out later with a threaded summary in the same
+// channel it opened in.
+type openAlert struct {
+	mm        *mattermostapi.MatterMost
+	client    *model.Client
+	category  string
+	namespace string
+	name      string
+	postID    string
+	openedAt  time.Time
+}
+
+var openAlerts = lru.New(maxOpenAlerts)
+
+// trackOpenAlert remembers a just-posted (or suppressed) notification under
+// corrID so a later Resolve call can find it. postID is the thread to
+// reply the resolution summary under, or "" if the notification itself was
+// suppressed - Resolve then posts a fresh top-level message instead.
+func trackOpenAlert(corrID string, mm *mattermostapi.MatterMost, client *model.Client, category, namespace, name, postID string) {
+	openAlerts.Set(corrID, openAlert{
+		mm:        mm,
+		client:    client,
+		category:  category,
+		namespace: namespace,
+		name:      name,
+		postID:    postID,
+		openedAt:  time.Now(),
+	})
+}
+
+// openAlertPostID returns the post ID trackOpenAlert remembered for corrID,
+// or "" if there's none tracked (or the notification itself was
+// suppressed) - for escalate to check whether that post has since been
+// acknowledged.
+func openAlertPostID(corrID string) string {
+	value, ok := openAlerts.Get(corrID)
+	if !ok {
+		return ""
+	}
+	return value.(openAlert).postID
+}
+
+// Resolve closes out the alert tagged corrID: posts a structured summary
+// (duration, rootCause, actions taken) - threaded under the original
+// notification when one was posted, as a new message otherwise - and
+// records it in resolution.Default so !postmortem and reports can show
+// categorized outcomes.
+//
+// The root cause tag is typed into the !resolve command; resolving an
+// alert is not offered as an interactive message button.
+func Resolve(corrID, rootCause, actions string) (resolution.Record, error) {
+	value, ok := openAlerts.Get(corrID)
+	if !ok {
+		return resolution.Record{}, fmt.Errorf("no open alert tracked for %s", corrID)
+	}
+	a := value.(openAlert)
+	duration := time.Since(a.openedAt).Round(time.Second)
+
+	summary := fmt.Sprintf("DeployBot - resolved _(ref: %s)_ - root cause: %s - duration: %s", corrID, rootCause, duration)
+	if actions != "" {
+		summary += " - actions: " + actions
+	}
+	if a.mm != nil && a.client != nil {
+		if a.postID != "" {
+			a.mm.PostReply(a.client, a.postID, summary)
+		} else {
+			a.mm.PostMessage(a.client, summary)
+		}
+	}
+
+	r := resolution.Record{
+		CorrelationID: corrID,
+		Category:      a.category,
+		Namespace:     a.namespace,
+		Name:          a.name,
+		RootCause:     rootCause,
+		Actions:       actions,
+		OpenedAt:      a.openedAt,
+		ResolvedAt:    time.Now(),
+		Duration:      duration,
+	}
+	resolution.Default.Append(r)
+	return r, nil
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podMetricsResource is metrics-server's aggregated API for per-Pod
+// container usage. This tree has no vendored k8s.io/metrics clientset, so
+// it's read the same way the OpenShift resources are - through the plain
+// dynamic client, treating the response as unstructured.Unstructured -
+// which is enough since this only ever reads usage.cpu/usage.memory back
+// out.
+var podMetricsResource = dynamicResource{GroupVersion: "metrics.k8s.io/v1beta1", Resource: "pods", Kind: "PodMetrics", Namespaced: true}
+
+// podResourceUsageLine returns one line per container in pod reporting its
+// current CPU/memory usage from metrics-server against that container's
+// configured requests/limits, or "" if metricsServerEnabled is off,
+// sharedConfig isn't set yet, or the lookup fails (e.g. metrics-server
+// isn't installed on this cluster) - callers append it to a message only
+// when non-empty, so a cluster without metrics-server just gets the
+// message it always got.
+func podResourceUsageLine(pod *api_v1.Pod) string {
+	if !metricsServerEnabled || sharedConfig == nil {
+		return ""
+	}
+
+	client, err := dynamicResourceClient(sharedConfig, podMetricsResource, pod.Namespace)
+	if err != nil {
+		return ""
+	}
+	obj, err := client.Get(pod.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	u, ok := obj.Object["containers"]
+	containers, ok2 := u.([]interface{})
+	if !ok || !ok2 {
+		return ""
+	}
+
+	requests := containerResources(pod)
+	var lines []string
+	for _, c := range containers {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := cm["name"].(string)
+		usage, _ := cm["usage"].(map[string]interface{})
+		cpu, _ := usage["cpu"].(string)
+		mem, _ := usage["memory"].(string)
+		lines = append(lines, fmt.Sprintf("%s: cpu %s/%s, memory %s/%s",
+			name, cpu, requests[name].cpuLimit, mem, requests[name].memLimit))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "current usage vs request/limit - " + strings.Join(lines, "; ")
+}
+
+// containerRequestLimits is one container's configured CPU/memory
+// request-or-limit strings, rendered "none" when unset, for display
+// alongside its live usage from metrics-server.
+type containerRequestLimits struct {
+	cpuLimit string
+	memLimit string
+}
+
+// containerResources reads pod's per-container requests/limits, preferring
+// the limit (the number usage is actually bounded by) and falling back to
+// the request when no limit is set.
+func containerResources(pod *api_v1.Pod) map[string]containerRequestLimits {
+	out := make(map[string]containerRequestLimits, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		out[c.Name] = containerRequestLimits{
+			cpuLimit: resourceQuantity(c, api_v1.ResourceCPU),
+			memLimit: resourceQuantity(c, api_v1.ResourceMemory),
+		}
+	}
+	return out
+}
+
+// resourceQuantity renders container c's limit for name, falling back to
+// its request, or "none" if neither is set.
+func resourceQuantity(c api_v1.Container, name api_v1.ResourceName) string {
+	if q, ok := c.Resources.Limits[name]; ok {
+		return q.String()
+	}
+	if q, ok := c.Resources.Requests[name]; ok {
+		return q.String()
+	}
+	return "none"
+}
+
+// deploymentResourceUsageLine returns podResourceUsageLine for up to 3 Pods
+// currently backing deployment, so a rollout notification can show live
+// usage without the message growing unbounded on a Deployment with many
+// replicas. Returns "" under the same conditions podResourceUsageLine does,
+// or if deployment's Pods can't be listed.
+func deploymentResourceUsageLine(clientset kubernetes.Interface, namespace string, deployment interface{}) string {
+	if !metricsServerEnabled || sharedConfig == nil {
+		return ""
+	}
+
+	selector, ok := deploymentSelector(deployment)
+	if !ok {
+		return ""
+	}
+	labelSelector, err := meta_v1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return ""
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(meta_v1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil || len(pods.Items) == 0 {
+		return ""
+	}
+
+	const maxPods = 3
+	var lines []string
+	for i := range pods.Items {
+		if i >= maxPods {
+			break
+		}
+		pod := &pods.Items[i]
+		if line := podResourceUsageLine(pod); line != "" {
+			lines = append(lines, pod.Name+" ("+line+")")
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	if len(pods.Items) > maxPods {
+		lines = append(lines, fmt.Sprintf("+%d more", len(pods.Items)-maxPods))
+	}
+	return strings.Join(lines, "; ")
+}
+
+// deploymentSelector extracts obj's label selector, matching the
+// type-switch deploymentRolloutStatus already uses to accept either
+// Deployment version this tree supports.
+func deploymentSelector(obj interface{}) (*meta_v1.LabelSelector, bool) {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		return d.Spec.Selector, true
+	case *appsv1beta1.Deployment:
+		return d.Spec.Selector, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+)
+
+// rolloutCondition collapses the fields of a DeploymentCondition that
+// rollout tracking cares about, across the apps/v1 and apps/v1beta1
+// representations.
+type rolloutCondition struct {
+	Type   string
+	Status string
+	Reason string
+}
+
+// rolloutStatus is the subset of a Deployment's status needed to tell
+// whether a rollout triggered by a spec change has finished, and if so
+// whether it succeeded.
+type rolloutStatus struct {
+	Generation         int64
+	ObservedGeneration int64
+	Conditions         []rolloutCondition
+	ReadyReplicas      int32
+}
+
+// deploymentRolloutStatus reads rolloutStatus off either an apps/v1 or an
+// apps/v1beta1 Deployment.
+func deploymentRolloutStatus(obj interface{}) (rolloutStatus, bool) {
+	switch d := obj.(type) {
+	case *appsv1.Deployment:
+		conditions := make([]rolloutCondition, 0, len(d.Status.Conditions))
+		for _, c := range d.Status.Conditions {
+			conditions = append(conditions, rolloutCondition{Type: string(c.Type), Status: string(c.Status), Reason: c.Reason})
+		}
+		return rolloutStatus{Generation: d.Generation, ObservedGeneration: d.Status.ObservedGeneration, Conditions: conditions, ReadyReplicas: d.Status.ReadyReplicas}, true
+	case *appsv1beta1.Deployment:
+		conditions := make([]rolloutCondition, 0, len(d.Status.Conditions))
+		for _, c := range d.Status.Conditions {
+			conditions = append(conditions, rolloutCondition{Type: string(c.Type), Status: string(c.Status), Reason: c.Reason})
+		}
+		return rolloutStatus{Generation: d.Generation, ObservedGeneration: d.Status.ObservedGeneration, Conditions: conditions, ReadyReplicas: d.Status.ReadyReplicas}, true
+	default:
+		return rolloutStatus{}, false
+	}
+}
+
+// progressLine renders a rollout's replica progress as the
+// "3/6 replicas ready" / "6/6 replicas ready ✅" line
+// reportRolloutOutcome keeps editing in place as a Deployment rolls out.
+func progressLine(name string, ready, desired int32, done bool) string {
+	line := fmt.Sprintf("DeployBot - rollout of %s: %d/%d replicas ready", name, ready, desired)
+	if done {
+		line += " ✅"
+	}
+	return line
+}
+
+// rolloutOutcome reports whether s reflects a finished rollout - one whose
+// latest spec generation has been observed and whose Progressing condition
+// has settled - and, if so, whether it succeeded. The reason returned on
+// failure is usually "ProgressDeadlineExceeded".
+func rolloutOutcome(s rolloutStatus) (done, succeeded bool, reason string) {
+	if s.ObservedGeneration < s.Generation {
+		return false, false, ""
+	}
+	for _, c := range s.Conditions {
+		if c.Type != "Progressing" {
+			continue
+		}
+		switch {
+		case c.Status == "True" && c.Reason == "NewReplicaSetAvailable":
+			return true, true, c.Reason
+		case c.Status == "False":
+			return true, false, c.Reason
+		}
+	}
+	return false, false, ""
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"DeployBot/rules"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+// rolloutResource is Argo Rollouts' custom resource. Like the OCP resources
+// in openshift.go, this tree has no generated typed clientset for it, so it's watched
+// through the dynamic client and read back as unstructured.Unstructured.
+var rolloutResource = dynamicResource{GroupVersion: "argoproj.io/v1alpha1", Resource: "rollouts", Kind: "Rollout", Namespaced: true}
+
+func newControllerRollout(restConfig *rest.Config, nsFilter NamespaceFilter) (*Controller, error) {
+	return newDynamicController(restConfig, rolloutResource, nsFilter, false)
+}
+
+// argoRolloutStatus is the subset of a Rollout's status this package narrates -
+// step progression, pause, and abort/rollback - pulled out of the
+// unstructured object by hand since there's no typed argoproj.io/v1alpha1
+// package vendored here.
+type argoRolloutStatus struct {
+	phase            string
+	currentStepIndex int64
+	totalSteps       int64
+	paused           bool
+	pauseReason      string
+	abort            bool
+}
+
+func readArgoRolloutStatus(u *unstructured.Unstructured) argoRolloutStatus {
+	var rs argoRolloutStatus
+	rs.phase, _ = unstructured.NestedString(u.Object, "status", "phase")
+	rs.currentStepIndex, _ = unstructured.NestedInt64(u.Object, "status", "currentStepIndex")
+	rs.abort, _ = unstructured.NestedBool(u.Object, "status", "abort")
+
+	if steps, found := unstructured.NestedSlice(u.Object, "spec", "strategy", "canary", "steps"); found {
+		rs.totalSteps = int64(len(steps))
+	}
+
+	if conditions, found := unstructured.NestedSlice(u.Object, "status", "pauseConditions"); found && len(conditions) > 0 {
+		rs.paused = true
+		if cond, ok := conditions[0].(map[string]interface{}); ok {
+			rs.pauseReason, _ = unstructured.NestedString(cond, "reason")
+		}
+	}
+
+	return rs
+}
+
+// processRolloutItem narrates an Argo Rollout's progressive delivery -
+// which canary step it's on, whether it's paused (and why), and whether it
+// was aborted/rolled back - so a canary's progress shows up live instead of
+// only as a final success/failure.
+func (c *Controller) processRolloutItem(key string, obj interface{}, exists bool) error {
+	mm := openshiftMatterMost()
+
+	if !exists {
+		client := mm.GetClient()
+		e := rules.Event{Kind: "Rollout", Name: key, Reason: "Deleted", Message: "Deleted Rollout " + key}
+		e.CorrelationID = c.notify(mm, client, "DeployBot - Deleted Rollout "+key, e)
+		c.runRules(e)
+		return nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	status := readArgoRolloutStatus(u)
+	reason, message := rolloutReasonAndMessage(u.GetName(), status)
+
+	client := mm.GetClient()
+	e := rules.Event{
+		Namespace: u.GetNamespace(),
+		Kind:      "Rollout",
+		Name:      u.GetName(),
+		Reason:    reason,
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
+
+func rolloutReasonAndMessage(name string, status argoRolloutStatus) (reason, message string) {
+	switch {
+	case status.abort:
+		return "Aborted", fmt.Sprintf("DeployBot - Rollout %s aborted, rolling back", name)
+	case status.paused:
+		reason := "Paused"
+		if status.pauseReason != "" {
+			return reason, fmt.Sprintf("DeployBot - Rollout %s paused at step %d/%d (%s)", name, status.currentStepIndex+1, status.totalSteps, status.pauseReason)
+		}
+		return reason, fmt.Sprintf("DeployBot - Rollout %s paused at step %d/%d", name, status.currentStepIndex+1, status.totalSteps)
+	case status.phase == "Degraded":
+		return "Degraded", fmt.Sprintf("DeployBot - Rollout %s degraded", name)
+	default:
+		return "Progressing", fmt.Sprintf("DeployBot - Rollout %s progressing - step %d/%d (%s)", name, status.currentStepIndex+1, status.totalSteps, status.phase)
+	}
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"DeployBot/metrics"
+	"DeployBot/rules"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// pvcPendingThreshold is how long a PVC can sit in Pending before it's
+// reported as stuck, rather than just newly created and waiting on its
+// provisioner.
+const pvcPendingThreshold = 5 * time.Minute
+
+func newControllerPVC(client kubernetes.Interface, nsFilter NamespaceFilter) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims(meta_v1.NamespaceAll).List(withFieldSelector("PersistentVolumeClaim", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumeClaims(meta_v1.NamespaceAll).Watch(withFieldSelector("PersistentVolumeClaim", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.PersistentVolumeClaim{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.PersistentVolumeClaim{}, resync, cache.Indexers{})
+	})
+
+	enqueue := func(obj interface{}) {
+		if pvc, ok := obj.(*api_v1.PersistentVolumeClaim); ok && !nsFilter.Allowed(pvc.Namespace) {
+			return
+		}
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err == nil {
+			queue.Add(key)
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(old, new interface{}) { enqueue(new) },
+	})
+
+	return &Controller{
+		logger:     logrus.WithField("pkg", "kubewatch-pvc"),
+		clientset:  client,
+		informer:   informer,
+		queue:      queue,
+		rules:      &rules.Engine{},
+		dispatcher: rules.NewDispatcher(),
+		nsFilter:   nsFilter,
+		kind:       "PersistentVolumeClaim",
+		latency:    metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		pvcAlerted: make(map[string]bool),
+	}
+}
+
+func newControllerPV(client kubernetes.Interface) *Controller {
+	queue := workqueue.NewRateLimitingQueue(newRateLimiter())
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumes().List(withFieldSelector("PersistentVolume", options))
+		},
+		WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumes().Watch(withFieldSelector("PersistentVolume", options))
+		},
+	}
+	informer := newSharedIndexInformer(&api_v1.PersistentVolume{}, func(_ kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(listWatch, &api_v1.PersistentVolume{}, resync, cache.Indexers{})
+	})
+
+	c := &Controller{
+		logger:          logrus.WithField("pkg", "kubewatch-pv"),
+		clientset:       client,
+		informer:        informer,
+		queue:           queue,
+		rules:           &rules.Engine{},
+		dispatcher:      rules.NewDispatcher(),
+		kind:            "PersistentVolume",
+		latency:         metrics.NewLatencyTracker(map[string]time.Duration{"DevopsBot": 5 * time.Second}),
+		pvWasBoundAtDel: make(map[string]bool),
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			if pv, ok := obj.(*api_v1.PersistentVolume); ok {
+				c.pvWasBoundAtDel[key] = pv.Status.Phase == api_v1.VolumeBound
+			}
+			queue.Add(key)
+		},
+	})
+
+	return c
+}
+
+// processPVCItem alerts once when a PVC has sat in Pending longer than
+// pvcPendingThreshold, and clears the alert once it binds.
+func (c *Controller) processPVCItem(key string, Obj interface{}, exists bool) error {
+	if !exists {
+		c.pvcAlerted[key] = false
+		return nil
+	}
+
+	pvc, ok := Obj.(*api_v1.PersistentVolumeClaim)
+	if !ok {
+		return fmt.Errorf("unexpected object type for key %s", key)
+	}
+
+	if pvc.Status.Phase != api_v1.ClaimPending {
+		c.pvcAlerted[key] = false
+		return nil
+	}
+	if time.Since(pvc.CreationTimestamp.Time) < pvcPendingThreshold {
+		return nil
+	}
+	if c.pvcAlerted[key] {
+		return nil
+	}
+	c.pvcAlerted[key] = true
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+	message := fmt.Sprintf("DeployBot - ALERT: PVC %s has been Pending for over %s", key, pvcPendingThreshold)
+	e := rules.Event{
+		Namespace: pvc.Namespace,
+		Kind:      "PersistentVolumeClaim",
+		Name:      pvc.Name,
+		Reason:    "Pending",
+		Message:   message,
+	}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
+
+// processPVItem alerts when a PV that was Bound at the moment it was
+// deleted disappears, since that silently breaks whatever deployment was
+// using it - a PV that was merely Available or Released is expected
+// cleanup and doesn't alert.
+func (c *Controller) processPVItem(key string, Obj interface{}, exists bool) error {
+	if exists {
+		return nil
+	}
+	wasBound := c.pvWasBoundAtDel[key]
+	delete(c.pvWasBoundAtDel, key)
+	if !wasBound {
+		return nil
+	}
+
+	mm := sharedMM.WithChannel("ops")
+	client := mm.GetClient()
+	message := "DeployBot - ALERT: PersistentVolume " + key + " was deleted"
+	e := rules.Event{Kind: "PersistentVolume", Name: key, Reason: "Deleted", Message: message}
+	e.CorrelationID = c.notify(mm, client, message, e)
+	c.runRules(e)
+	return nil
+}
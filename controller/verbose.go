@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"DeployBot/verbose"
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// streamPodTransition posts newPod's phase change into every thread
+// currently running "!verbose on" for its namespace/name. It bypasses the
+// workqueue and the rules/notify pipeline entirely - this is a best-effort,
+// time-limited debug stream, not a retried alert, so a failed post here is
+// logged and dropped rather than requeued.
+func streamPodTransition(oldPod, newPod *api_v1.Pod) {
+	threads := verbose.Threads(newPod.Namespace, newPod.Name)
+	if len(threads) == 0 {
+		return
+	}
+
+	mm := sharedMM.WithChannel("DevopsBot")
+	client, err := mm.TryGetClient()
+	if err != nil {
+		logrus.WithError(err).Warn("verbose: couldn't get mattermost client")
+		return
+	}
+
+	message := fmt.Sprintf("DeployBot - Pod %s/%s: %s -> %s", newPod.Namespace, newPod.Name, oldPod.Status.Phase, newPod.Status.Phase)
+	for _, rootId := range threads {
+		mm.PostReply(client, rootId, message)
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// wrongSeverity ranks a whatsWrongFinding the same coarse way rules.Severity
+// does, so the summary can lead with the most urgent findings without
+// pulling in the rules package just for a 3-way ordering.
+type wrongSeverity int
+
+const (
+	wrongInfo wrongSeverity = iota
+	wrongWarning
+	wrongCritical
+)
+
+func (s wrongSeverity) String() string {
+	switch s {
+	case wrongCritical:
+		return "critical"
+	case wrongWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// whatsWrongFinding is one unhealthy object surfaced by WhatsWrong.
+type whatsWrongFinding struct {
+	Severity  wrongSeverity
+	Namespace string
+	Kind      string
+	Name      string
+	Detail    string
+}
+
+// WhatsWrong scans every running controller's informer cache - no extra API
+// calls, just what's already cached for the live watch - for objects that
+// look unhealthy right now (Deployments with unavailable replicas, Pods
+// stuck Pending, failing readiness probes, or crash-looping) and returns a
+// prioritized plain-text summary. If namespace is non-empty, only findings
+// in that namespace are included. Returns "no running controllers" if
+// Start() hasn't run yet, and "nothing looks wrong right now" if the scan
+// comes back clean.
+func WhatsWrong(namespace string) string {
+	if runningManager == nil {
+		return "no running controllers - has Start() run yet?"
+	}
+
+	var findings []whatsWrongFinding
+	for _, c := range runningManager.controllers {
+		findings = append(findings, c.whatsWrong(namespace)...)
+	}
+
+	if len(findings) == 0 {
+		if namespace != "" {
+			return fmt.Sprintf("nothing looks wrong in %q right now", namespace)
+		}
+		return "nothing looks wrong right now"
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "DeployBot - %d issue(s) found:\n", len(findings))
+	for _, f := range findings {
+		fmt.Fprintf(&b, "- [%s] %s/%s %s: %s\n", f.Severity, f.Namespace, f.Kind, f.Name, f.Detail)
+	}
+	return b.String()
+}
+
+// whatsWrong inspects c's informer cache for unhealthy objects of c's kind.
+// Kinds this check doesn't know how to assess (Services, ConfigMaps, ...)
+// return no findings rather than guessing.
+func (c *Controller) whatsWrong(namespace string) []whatsWrongFinding {
+	if c.informer == nil {
+		return nil
+	}
+
+	var findings []whatsWrongFinding
+	for _, obj := range c.informer.GetIndexer().List() {
+		switch c.kind {
+		case "Deployment":
+			switch dep := obj.(type) {
+			case *appsv1.Deployment:
+				findings = append(findings, deploymentFindings(dep.Namespace, dep.Name, dep.Status.Replicas, dep.Status.AvailableReplicas)...)
+			case *appsv1beta1.Deployment:
+				findings = append(findings, deploymentFindings(dep.Namespace, dep.Name, dep.Status.Replicas, dep.Status.AvailableReplicas)...)
+			}
+		case "Pod":
+			if pod, ok := obj.(*api_v1.Pod); ok {
+				findings = append(findings, podFindings(pod)...)
+			}
+		}
+	}
+
+	if namespace == "" {
+		return findings
+	}
+	var filtered []whatsWrongFinding
+	for _, f := range findings {
+		if f.Namespace == namespace {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// deploymentFindings flags a Deployment whose available replicas have
+// fallen short of its replica count. This is a separate signal from the
+// Progressing condition reportRolloutOutcome judges a rollout by.
+func deploymentFindings(namespace, name string, desired, available int32) []whatsWrongFinding {
+	if desired == 0 || available >= desired {
+		return nil
+	}
+	return []whatsWrongFinding{{
+		Severity:  wrongCritical,
+		Namespace: namespace,
+		Kind:      "Deployment",
+		Name:      name,
+		Detail:    fmt.Sprintf("%d/%d replicas available", available, desired),
+	}}
+}
+
+// podRestartThreshold is how many restarts on a single container, within
+// its current run, counts as crash-looping rather than the occasional
+// recoverable restart.
+const podRestartThreshold = 5
+
+// podFindings flags a Pod stuck Pending, a container failing its readiness
+// probe (Ready: false past Running), or a container restarting repeatedly.
+func podFindings(pod *api_v1.Pod) []whatsWrongFinding {
+	var findings []whatsWrongFinding
+
+	if pod.Status.Phase == api_v1.PodPending {
+		findings = append(findings, whatsWrongFinding{
+			Severity:  wrongWarning,
+			Namespace: pod.Namespace,
+			Kind:      "Pod",
+			Name:      pod.Name,
+			Detail:    "stuck Pending",
+		})
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if pod.Status.Phase == api_v1.PodRunning && !cs.Ready && cs.State.Running != nil {
+			findings = append(findings, whatsWrongFinding{
+				Severity:  wrongWarning,
+				Namespace: pod.Namespace,
+				Kind:      "Pod",
+				Name:      pod.Name,
+				Detail:    fmt.Sprintf("container %s running but not ready", cs.Name),
+			})
+		}
+		if cs.RestartCount >= podRestartThreshold {
+			findings = append(findings, whatsWrongFinding{
+				Severity:  wrongCritical,
+				Namespace: pod.Namespace,
+				Kind:      "Pod",
+				Name:      pod.Name,
+				Detail:    fmt.Sprintf("container %s restarted %d times", cs.Name, cs.RestartCount),
+			})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,94 @@
+// Package credcheck periodically re-validates the credentials DeployBot
+// depends on (its own Mattermost login, AWS keys, configured notifier
+// backends) and alerts once one of them stops working, so a secret that
+// was rotated everywhere except DeployBot's own config is caught before
+// a real deploy notification silently fails to post.
+package credcheck
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Check is one credential's liveness probe - a nil error means the
+// credential still works.
+type Check struct {
+	Name  string
+	Probe func() error
+}
+
+// Checker runs a set of Checks on a fixed interval, alerting once when a
+// Check starts failing and once more when it recovers, the same
+// once-on-change pattern DeployBot already uses for endpoint and PVC
+// alerts - rotating the credential back in and reloading config clears
+// the alert on the next tick.
+type Checker struct {
+	mu       sync.Mutex
+	checks   []Check
+	failing  map[string]bool
+	interval time.Duration
+	alert    func(message string)
+}
+
+// NewChecker builds a Checker that runs every interval and posts via
+// alert.
+func NewChecker(interval time.Duration, alert func(message string)) *Checker {
+	return &Checker{
+		failing:  make(map[string]bool),
+		interval: interval,
+		alert:    alert,
+	}
+}
+
+// Add registers check to be run on every tick.
+func (c *Checker) Add(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Start runs every registered Check immediately, then again every
+// interval, until stopCh closes.
+func (c *Checker) Start(stopCh <-chan struct{}) {
+	c.runOnce()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.runOnce()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (c *Checker) runOnce() {
+	c.mu.Lock()
+	checks := append([]Check{}, c.checks...)
+	c.mu.Unlock()
+
+	for _, check := range checks {
+		err := check.Probe()
+
+		c.mu.Lock()
+		wasFailing := c.failing[check.Name]
+		c.failing[check.Name] = err != nil
+		c.mu.Unlock()
+
+		if err != nil {
+			logrus.WithError(err).WithField("credential", check.Name).Warn("credential health check failed")
+			if !wasFailing {
+				c.alert(fmt.Sprintf("DeployBot - credential check failed for %s: %v. Rotate it and reload config to clear this alert.", check.Name, err))
+			}
+			continue
+		}
+		if wasFailing {
+			c.alert(fmt.Sprintf("DeployBot - credential check recovered for %s", check.Name))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"DeployBot/audit"
+	"DeployBot/controller"
+	"DeployBot/guard"
+	"DeployBot/incident"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// debugPodScript gathers the basic diagnostics !debug-pod reports back -
+// process list, disk usage, and env var names only, never values, since
+// those can carry secrets.
+const debugPodScript = "echo PS:; ps aux 2>&1; echo DF:; df -h 2>&1; echo ENV:; env 2>&1 | cut -d= -f1"
+
+// debugPodCommand implements `!debug-pod <namespace>/<pod> [container]`,
+// restricted via authorized() and logged to incident.DefaultLog for
+// audit. Real Kubernetes ephemeral containers (a separate debug
+// container injected into a running Pod) aren't supported by the
+// client-go version vendored in this tree, so this execs the diagnostic
+// script directly in the target container instead - same diagnostics,
+// without the isolation a true ephemeral container would give.
+func debugPodCommand(message, userId string) string {
+	fields := strings.Fields(message)
+	if len(fields) < 2 || len(fields) > 3 || !strings.Contains(fields[1], "/") {
+		return guard.Ephemeral("Usage: !debug-pod <namespace>/<pod> [container]")
+	}
+	parts := strings.SplitN(fields[1], "/", 2)
+	namespace, podName := parts[0], parts[1]
+
+	if !authorized(userId, "debug-pod", fields[1]) {
+		return guard.Ephemeral("DeployBot - !debug-pod is restricted to admins.")
+	}
+
+	client := controller.Client()
+	config := controller.RESTConfig()
+	if client == nil || config == nil {
+		return "DeployBot - Kubernetes client isn't available yet."
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(podName, meta_v1.GetOptions{})
+	if err != nil {
+		return fmt.Sprintf("DeployBot - couldn't get pod %s/%s: %v", namespace, podName, err)
+	}
+
+	container := ""
+	if len(fields) == 3 {
+		container = fields[2]
+	} else if len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	incident.DefaultLog.Append(incident.Record{
+		Time:      time.Now(),
+		Namespace: namespace,
+		Kind:      "Pod",
+		Name:      podName,
+		Reason:    "DebugExec",
+		Message:   fmt.Sprintf("%s ran !debug-pod against container %s", userId, container),
+	})
+	if err := audit.Default.Append(userId, "debug-pod", namespace+"/"+podName, "container "+container); err != nil {
+		incident.DefaultLog.Append(incident.Record{Time: time.Now(), Namespace: namespace, Kind: "Pod", Name: podName, Reason: "AuditWriteFailed", Message: err.Error()})
+	}
+
+	stdout, stderr, err := execInPod(client, config, namespace, podName, container, debugPodScript)
+	if err != nil {
+		return fmt.Sprintf("DeployBot - debug-pod exec failed: %v\n%s", err, stderr)
+	}
+	return fmt.Sprintf("DeployBot - diagnostics for %s/%s (container %s):\n%s", namespace, podName, container, stdout)
+}
+
+// execInPod runs command in namespace/podName's container via the exec
+// subresource, returning its captured stdout and stderr.
+func execInPod(client kubernetes.Interface, config *rest.Config, namespace, podName, container, command string) (string, string, error) {
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&api_v1.PodExecOptions{
+			Container: container,
+			Command:   []string{"sh", "-c", command},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return "", "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = exec.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	return stdout.String(), stderr.String(), err
+}
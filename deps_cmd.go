@@ -0,0 +1,17 @@
+package main
+
+import (
+	"DeployBot/controller"
+	"DeployBot/guard"
+	"strings"
+)
+
+// depsCommand implements `!deps <service>`, rendering the dependency tree
+// declared via deploybot.io/depends-on annotations rooted at service.
+func depsCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 {
+		return guard.Ephemeral("Usage: !deps <service>")
+	}
+	return "DeployBot - dependency tree:\n" + controller.DependencyTree(fields[1])
+}
@@ -0,0 +1,106 @@
+// Package enrich attaches cloud/infrastructure context - instance type,
+// availability zone, spot vs on-demand - to node and pod notifications, so
+// a capacity alert carries enough to act on without someone pasting the
+// node name into kubectl describe first.
+package enrich
+
+import (
+	"strings"
+
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// Info is the infrastructure context enrichment attaches to a
+// node-related notification. Any field left empty means this Provider
+// couldn't determine it.
+type Info struct {
+	InstanceType string
+	Zone         string
+	CapacityType string // "spot", "on-demand", or "" if unknown
+}
+
+// String renders Info for a chat message, omitting anything unknown, and
+// "" if nothing at all was found.
+func (i Info) String() string {
+	var parts []string
+	if i.InstanceType != "" {
+		parts = append(parts, i.InstanceType)
+	}
+	if i.Zone != "" {
+		parts = append(parts, i.Zone)
+	}
+	if i.CapacityType != "" {
+		parts = append(parts, i.CapacityType)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Provider produces Info for a Node. A Provider backed by a live cloud
+// metadata API (e.g. AWS IMDS) could satisfy this interface without any
+// caller here changing - none is wired up yet, since this tree vendors no
+// EC2 client, only the Lex one in DeployBot/awslex, and LabelProvider
+// already covers the same fields from data the cloud-controller-manager
+// puts on the Node for free.
+type Provider interface {
+	Enrich(node *api_v1.Node) Info
+}
+
+// instanceTypeLabels and zoneLabels are checked newest-first:
+// Kubernetes 1.17 introduced stable replacements for several
+// "beta.kubernetes.io"/"failure-domain.beta.kubernetes.io" labels, but
+// older clusters and some cloud controllers still only set the beta ones.
+var (
+	instanceTypeLabels = []string{"node.kubernetes.io/instance-type", "beta.kubernetes.io/instance-type"}
+	zoneLabels         = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+)
+
+// LabelProvider is the default Provider: it reads instance type,
+// availability zone, and spot/on-demand status from the labels cloud
+// controller managers already set on every Node, with no extra API calls.
+type LabelProvider struct{}
+
+// Enrich implements Provider.
+func (LabelProvider) Enrich(node *api_v1.Node) Info {
+	return Info{
+		InstanceType: firstLabel(node, instanceTypeLabels),
+		Zone:         firstLabel(node, zoneLabels),
+		CapacityType: capacityType(node),
+	}
+}
+
+func firstLabel(node *api_v1.Node, keys []string) string {
+	for _, k := range keys {
+		if v := node.Labels[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// capacityType checks the spot/preemptible labels each major cloud sets on
+// its own managed node groups.
+func capacityType(node *api_v1.Node) string {
+	switch node.Labels["eks.amazonaws.com/capacityType"] {
+	case "SPOT":
+		return "spot"
+	case "ON_DEMAND":
+		return "on-demand"
+	}
+	if node.Labels["cloud.google.com/gke-spot"] == "true" || node.Labels["cloud.google.com/gke-preemptible"] == "true" {
+		return "spot"
+	}
+	if node.Labels["kubernetes.azure.com/scalesetpriority"] == "spot" {
+		return "spot"
+	}
+	return ""
+}
+
+// Default is the Provider every enrichment call in this package uses,
+// overridable via SetProvider once a live cloud-metadata-backed Provider
+// exists to swap in.
+var Default Provider = LabelProvider{}
+
+// SetProvider overrides Default.
+func SetProvider(p Provider) {
+	Default = p
+}
@@ -0,0 +1,103 @@
+// Package feedback tracks 👍/👎 reactions on DeployBot's own notification
+// messages, so `!feedback report` can summarize which alert categories
+// users find noisy - input for tuning rules and digests.
+package feedback
+
+import (
+	"DeployBot/lru"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxTracked bounds how many recent notification posts are remembered
+// while waiting for a reaction, so a long-running process doesn't grow
+// memory without bound.
+const maxTracked = 4096
+
+type tally struct {
+	up   int
+	down int
+}
+
+var (
+	mu      sync.Mutex
+	tallies = make(map[string]tally)
+	byPost  = lru.New(maxTracked)
+)
+
+// Track remembers that postID is a notification of the given category
+// (e.g. "Deployment/Created"), so a later reaction on it can be attributed.
+func Track(postID, category string) {
+	byPost.Set(postID, category)
+}
+
+// Add records a 👍/👎 reaction on a tracked post. Reactions on posts
+// DeployBot never tracked (or already evicted) are ignored. Any emoji
+// other than "+1"/"thumbsup" or "-1"/"thumbsdown" is ignored.
+func Add(postID, emojiName string) {
+	category, ok := byPost.Get(postID)
+	if !ok {
+		return
+	}
+
+	var up, down bool
+	switch emojiName {
+	case "+1", "thumbsup":
+		up = true
+	case "-1", "thumbsdown":
+		down = true
+	default:
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	t := tallies[category.(string)]
+	if up {
+		t.up++
+	}
+	if down {
+		t.down++
+	}
+	tallies[category.(string)] = t
+}
+
+// Report renders a summary of every category seen so far, ranked by how
+// noisy it looks (highest 👎 ratio first).
+func Report() string {
+	mu.Lock()
+	categories := make([]string, 0, len(tallies))
+	snapshot := make(map[string]tally, len(tallies))
+	for category, t := range tallies {
+		categories = append(categories, category)
+		snapshot[category] = t
+	}
+	mu.Unlock()
+
+	if len(categories) == 0 {
+		return "No feedback recorded yet."
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := snapshot[categories[i]], snapshot[categories[j]]
+		return noiseRatio(a) > noiseRatio(b)
+	})
+
+	var b strings.Builder
+	b.WriteString("Feedback by category (👍/👎):\n")
+	for _, category := range categories {
+		t := snapshot[category]
+		fmt.Fprintf(&b, "- %s: %d/%d\n", category, t.up, t.down)
+	}
+	return b.String()
+}
+
+func noiseRatio(t tally) float64 {
+	total := t.up + t.down
+	if total == 0 {
+		return 0
+	}
+	return float64(t.down) / float64(total)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"DeployBot/ack"
+	"DeployBot/feedback"
+	"DeployBot/guard"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// handleReactionAdded records a 👍/👎 reaction on one of DeployBot's own
+// notification posts for `!feedback report`, and a 👀/✅ reaction for ack -
+// the same event covers both since Mattermost doesn't distinguish why a
+// reaction was added.
+func handleReactionAdded(event *model.WebSocketEvent) {
+	raw, ok := event.Data["reaction"].(string)
+	if !ok {
+		return
+	}
+	reaction := model.ReactionFromJson(strings.NewReader(raw))
+	if reaction == nil {
+		return
+	}
+	feedback.Add(reaction.PostId, reaction.EmojiName)
+	ack.Add(reaction.PostId, reaction.EmojiName)
+}
+
+// feedbackCommand implements `!feedback report`.
+func feedbackCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 || fields[1] != "report" {
+		return guard.Ephemeral("Usage: !feedback report")
+	}
+	return feedback.Report()
+}
@@ -0,0 +1,199 @@
+// Package guard wraps chat command handlers and enrichers with panic
+// recovery, so a bug in one doesn't take the whole bot down, and disables a
+// handler automatically if it keeps panicking.
+package guard
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// disableThreshold panics within disableWindow trip a handler into the
+// disabled state, so a handler that's wedged (rather than just unlucky once)
+// stops being retried on every message until someone looks at the logs.
+const (
+	disableThreshold = 3
+	disableWindow    = 5 * time.Minute
+)
+
+// defaultCommandTimeout bounds how long Command waits for a handler before
+// replying early, if SetCommandTimeout hasn't overridden it.
+const defaultCommandTimeout = 30 * time.Second
+
+// commandTimeout is how long Command waits for a handler to finish before
+// handing back control with a resumable token instead of leaving the
+// channel silent. Overridden via SetCommandTimeout.
+var commandTimeout = defaultCommandTimeout
+
+// SetCommandTimeout lets main wire in config.Config.CommandTimeoutSeconds,
+// overriding commandTimeout for every command dispatched from this point
+// on.
+func SetCommandTimeout(d time.Duration) {
+	if d <= 0 {
+		d = defaultCommandTimeout
+	}
+	commandTimeout = d
+}
+
+// maxPendingResults bounds how many still-running (or recently finished but
+// unclaimed) commands are remembered, so a burst of slow commands nobody
+// ever resumes doesn't grow memory without bound - the oldest is simply
+// evicted.
+const maxPendingResults = 256
+
+// pendingResult is what a timed-out command's goroutine eventually fills in
+// once it actually finishes, for Resume to pick up later.
+type pendingResult struct {
+	mu     sync.Mutex
+	done   bool
+	result string
+}
+
+var (
+	pendingMu    sync.Mutex
+	pending      = make(map[string]*pendingResult)
+	pendingOrder []string
+)
+
+// registerPending stores p under a fresh token, evicting the oldest entry
+// once maxPendingResults is exceeded.
+func registerPending(p *pendingResult) string {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	token := fmt.Sprintf("cmd-%d", time.Now().UnixNano())
+	pending[token] = p
+	pendingOrder = append(pendingOrder, token)
+	if len(pendingOrder) > maxPendingResults {
+		oldest := pendingOrder[0]
+		pendingOrder = pendingOrder[1:]
+		delete(pending, oldest)
+	}
+	return token
+}
+
+// Resume looks up the result of a command Command previously timed out on,
+// for the `!resume <token>` command to report back once it's ready.
+func Resume(token string) (result string, done, found bool) {
+	pendingMu.Lock()
+	p, ok := pending[token]
+	pendingMu.Unlock()
+	if !ok {
+		return "", false, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.result, p.done, true
+}
+
+type handlerState struct {
+	panics   []time.Time
+	disabled bool
+}
+
+var (
+	mu     sync.Mutex
+	states = make(map[string]*handlerState)
+)
+
+// Enabled reports whether name's handler is still allowed to run.
+func Enabled(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	s := states[name]
+	return s == nil || !s.disabled
+}
+
+func recordPanic(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	s := states[name]
+	if s == nil {
+		s = &handlerState{}
+		states[name] = s
+	}
+	cutoff := time.Now().Add(-disableWindow)
+	kept := s.panics[:0]
+	for _, t := range s.panics {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	s.panics = append(kept, time.Now())
+	if len(s.panics) >= disableThreshold {
+		s.disabled = true
+	}
+}
+
+// Protect runs fn with panic recovery. If name's handler is currently
+// disabled, or fn panics, onError is called with a friendly message instead
+// - a panic is also logged with its stack and counted toward disabling the
+// handler if it keeps happening.
+func Protect(name string, onError func(message string), fn func()) {
+	if !Enabled(name) {
+		onError(fmt.Sprintf("DeployBot - %s is temporarily disabled after repeated errors, check the logs.", name))
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("handler %q panicked: %v\n%s", name, r, debug.Stack())
+			recordPanic(name)
+			onError(fmt.Sprintf("DeployBot - %s hit an internal error and couldn't complete.", name))
+		}
+	}()
+	fn()
+}
+
+// ephemeralMarker prefixes a reply that Ephemeral has marked as
+// user-specific, so the caller posting it - main.ProcessMessage, or
+// adminapi's /command endpoint - can tell it apart from a normal reply
+// without changing every handler's return type.
+const ephemeralMarker = "\x00ephemeral\x00"
+
+// Ephemeral marks reply as visible only to the requesting user rather than
+// posted to the whole channel - for usage errors and permission denials
+// that would otherwise clutter a shared channel with bot back-and-forth
+// nobody else needs to see.
+func Ephemeral(reply string) string {
+	return ephemeralMarker + reply
+}
+
+// SplitEphemeral strips the marker Ephemeral adds, reporting whether reply
+// was marked ephemeral in the first place.
+func SplitEphemeral(reply string) (message string, ephemeral bool) {
+	if strings.HasPrefix(reply, ephemeralMarker) {
+		return strings.TrimPrefix(reply, ephemeralMarker), true
+	}
+	return reply, false
+}
+
+// Command wraps a `!command` handler (message string -> reply string) with
+// Protect and commandTimeout: if fn hasn't replied within commandTimeout,
+// Command hands back a resumable token right away and lets fn keep running
+// in the background, so a slow API call doesn't leave the channel silent.
+// The eventual result is fetched with `!resume <token>`.
+func Command(name string, fn func(message string) string) func(message string) string {
+	return func(message string) string {
+		done := make(chan string, 1)
+		go Protect(name, func(m string) { done <- m }, func() { done <- fn(message) })
+
+		select {
+		case reply := <-done:
+			return reply
+		case <-time.After(commandTimeout):
+			p := &pendingResult{}
+			token := registerPending(p)
+			go func() {
+				reply := <-done
+				p.mu.Lock()
+				p.result = reply
+				p.done = true
+				p.mu.Unlock()
+			}()
+			return fmt.Sprintf("DeployBot - %s is taking longer than %s, still working - check back with `!resume %s`.", name, commandTimeout, token)
+		}
+	}
+}
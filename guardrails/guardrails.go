@@ -0,0 +1,71 @@
+// Package guardrails validates and sanitizes free-text replies before
+// they're posted to Mattermost or executed as a command, for call sites
+// whose output isn't a fixed template - most directly, a generative
+// (LLM-backed) responder. This tree has no such provider integrated yet
+// (every reply today comes from AWS Lex intent matching or a fixed Go
+// template), so Enforce has no caller - it exists as the layer config's
+// LLMEnabled flag documents a future LLM-backed responder would have to go
+// through before anything it produced reached a channel or the cluster.
+package guardrails
+
+import (
+	"fmt"
+	"regexp"
+
+	"DeployBot/audit"
+)
+
+// secretPatterns mirrors the kind of thing a generated reply could
+// accidentally echo back from a Secret, a kubeconfig, or an env dump.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key)\s*[=:]\s*\S+`),
+	regexp.MustCompile(`(?i)aws_secret_access_key\s*[=:]\s*\S+`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]+ PRIVATE KEY-----[\s\S]*?-----END [A-Z ]+ PRIVATE KEY-----`),
+}
+
+const redacted = "[REDACTED]"
+
+// Sanitize replaces anything in reply matching a secret pattern with
+// "[REDACTED]", returning the cleaned text and how many replacements it
+// made.
+func Sanitize(reply string) (string, int) {
+	count := 0
+	for _, p := range secretPatterns {
+		reply = p.ReplaceAllStringFunc(reply, func(string) string {
+			count++
+			return redacted
+		})
+	}
+	return reply, count
+}
+
+// Authorize reports whether userId may run command against target - the
+// same shape as this bot's own authorized() function in main, passed in
+// rather than imported directly to avoid a dependency cycle on package
+// main.
+type Authorize func(userId, command, target string) bool
+
+// Enforce is the full guardrail pipeline a generated reply should pass
+// through before it's posted or acted on: it strips anything matching a
+// secrets pattern, checks command/target against authorize when the reply
+// names a command to run, and always records what happened in w so a
+// generated reply has the same audit trail as any other privileged action.
+// It returns the cleaned reply, or an error if command is not authorized
+// (the reply is never returned in that case).
+func Enforce(w *audit.Writer, userId, command, target, reply string, authorize Authorize) (string, error) {
+	cleaned, redactions := Sanitize(reply)
+
+	if command != "" && !authorize(userId, command, target) {
+		w.Append(userId, "llm-guardrail-blocked", target, command)
+		return "", fmt.Errorf("generated command %q on %q is not authorized for %s", command, target, userId)
+	}
+
+	detail := command
+	if redactions > 0 {
+		detail = fmt.Sprintf("%s (%d redaction(s))", detail, redactions)
+	}
+	w.Append(userId, "llm-guardrail", target, detail)
+
+	return cleaned, nil
+}
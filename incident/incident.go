@@ -0,0 +1,74 @@
+// Package incident keeps a bounded, in-memory timeline of the events
+// DeployBot's controllers have observed, so commands like !postmortem can
+// reconstruct what happened around an incident without a separate
+// datastore.
+package incident
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRecords bounds the timeline so a long-running process doesn't grow
+// memory without bound.
+const maxRecords = 5000
+
+// Record is one timeline entry - a Kubernetes event DeployBot reacted to.
+type Record struct {
+	Time      time.Time
+	Namespace string
+	Kind      string
+	Name      string
+	Reason    string
+	Message   string
+	// CorrelationID ties this record back to the same notification's chat
+	// message and any rule webhook payload it triggered, so !postmortem
+	// output can be cross-referenced with the other systems it touched.
+	CorrelationID string
+}
+
+// Log is a bounded, append-only timeline of Records, safe for concurrent
+// use.
+type Log struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// DefaultLog is the process-wide timeline controllers append to and chat
+// commands read from.
+var DefaultLog = New(maxRecords)
+
+// New builds a Log that keeps at most capacity records, dropping the
+// oldest once full.
+func New(capacity int) *Log {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Log{records: make([]Record, 0, capacity)}
+}
+
+// Append adds r to the timeline, evicting the oldest record if the log is
+// already at capacity.
+func (l *Log) Append(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.records) >= cap(l.records) {
+		l.records = append(l.records[1:], r)
+		return
+	}
+	l.records = append(l.records, r)
+}
+
+// Find returns every record whose namespace or name matches id, oldest
+// first, used to reconstruct an incident's timeline by resource name.
+func (l *Log) Find(id string) []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var matched []Record
+	for _, r := range l.records {
+		if r.Namespace == id || r.Name == id {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,41 @@
+// Package locale remembers each chat user's preferred language, so Lex
+// requests can be routed to a locale-specific bot alias instead of always
+// using the default English one.
+package locale
+
+import "sync"
+
+// DefaultLocale is used for any user who hasn't set a preference.
+const DefaultLocale = "en"
+
+// Store maps a Mattermost user ID to their preferred locale.
+type Store struct {
+	mu     sync.RWMutex
+	byUser map[string]string
+}
+
+// Default is the process-wide locale store.
+var Default = NewStore()
+
+// NewStore builds an empty Store.
+func NewStore() *Store {
+	return &Store{byUser: make(map[string]string)}
+}
+
+// Get returns userId's preferred locale, or DefaultLocale if they haven't
+// set one.
+func (s *Store) Get(userId string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if l, ok := s.byUser[userId]; ok {
+		return l
+	}
+	return DefaultLocale
+}
+
+// Set records userId's preferred locale.
+func (s *Store) Set(userId, locale string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byUser[userId] = locale
+}
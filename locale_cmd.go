@@ -0,0 +1,32 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"DeployBot/locale"
+	"strings"
+)
+
+// lexBotAlias resolves the Lex bot alias that should handle userId's
+// requests, based on their configured locale, falling back to the
+// locale.DefaultLocale alias if the user has no preference or their locale
+// has no alias configured.
+func lexBotAlias(userId string) string {
+	aliases := globalConfig.Snapshot().LexAliasesByLocale
+	if alias, ok := aliases[locale.Default.Get(userId)]; ok {
+		return alias
+	}
+	if alias, ok := aliases[locale.DefaultLocale]; ok {
+		return alias
+	}
+	return "devopsbot"
+}
+
+// localeCommand implements `!locale set <code>`.
+func localeCommand(message, userId string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 3 || fields[1] != "set" {
+		return guard.Ephemeral("Usage: !locale set <code>")
+	}
+	locale.Default.Set(userId, fields[2])
+	return "Locale set to " + fields[2]
+}
@@ -0,0 +1,94 @@
+// Package lru provides a small, size-bounded cache for callers that need
+// to remember recent keys (dedup sets, thread mappings, pagination
+// cursors) without letting a long-running process grow memory without
+// bound.
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Metrics reports a Cache's current occupancy and lifetime eviction count.
+type Metrics struct {
+	Capacity  int
+	Occupancy int
+	Evictions int64
+}
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// Cache is a fixed-capacity, least-recently-used cache safe for concurrent
+// use. Once full, adding a new key evicts the least recently used entry.
+type Cache struct {
+	mu        sync.Mutex
+	capacity  int
+	items     map[string]*list.Element
+	order     *list.List
+	evictions int64
+}
+
+// New builds a Cache that holds at most capacity entries. A capacity below
+// 1 is treated as 1.
+func New(capacity int) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the value stored for key, marking it as recently used.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Set stores value for key, evicting the least recently used entry if the
+// cache is already at capacity.
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&entry{key: key, value: value})
+	c.items[key] = el
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*entry).key)
+			c.evictions++
+		}
+	}
+}
+
+// Metrics reports the cache's current occupancy and lifetime evictions.
+func (c *Cache) Metrics() Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Metrics{
+		Capacity:  c.capacity,
+		Occupancy: c.order.Len(),
+		Evictions: c.evictions,
+	}
+}
@@ -1,25 +1,57 @@
 package main
 
 import (
+	"DeployBot/adminapi"
+	"DeployBot/audit"
 	"DeployBot/awslex"
+	"DeployBot/config"
+	"DeployBot/configsync"
+	"DeployBot/controller"
+	"DeployBot/credcheck"
+	"DeployBot/guard"
 	"DeployBot/mattermostapi"
+	"DeployBot/monitor"
+	"DeployBot/notifier"
+	"DeployBot/retry"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lexruntimeservice"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/mattermost/mattermost-server/model"
 )
 
 var client *model.Client
 
+// deployLimiter caps concurrent bot-driven deployments per environment so,
+// for example, only one rollout orchestration runs in prod at a time.
+var deployLimiter = newOpLimiter(1)
+
+// globalConfig is the running config, set once in main. ProcessMessage reads
+// it to look up per-locale Lex bot aliases.
+var globalConfig *config.Config
+
+// botMatterMost is the bot's own Mattermost identity, set once in main.
+// Command handlers that need to post outside the reply dispatchCommand's
+// caller already posts - e.g. sudoCommand DMing a confirmation code - use
+// it instead of building their own.
+var botMatterMost *mattermostapi.MatterMost
+
+// monitorManager runs the synthetic uptime checks registered via
+// `!monitor add`, set once in main.
+var monitorManager *monitor.Manager
+
 func basicAuth(project string) string {
 	var username string = "user1"
 	var passwd string = "abcdef"
@@ -95,7 +127,112 @@ func basicStatus(project string) (string, string) {
 
 func main() {
 
-	//go controller.Start()
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench()
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1] == "audit" && os.Args[2] == "verify" {
+		runAuditVerify()
+		return
+	}
+
+	cfg, err := config.Load("config.json")
+	if err != nil {
+		log.Fatal("Couldn't load config: ", err)
+	}
+	globalConfig = cfg
+
+	gitRepoURL := os.Getenv("DEPLOYBOT_CONFIG_GIT_REPO")
+	if err := cfg.ValidateOffline(gitRepoURL); err != nil {
+		log.Fatal("Offline mode validation failed: ", err)
+	}
+
+	auditPath := os.Getenv("DEPLOYBOT_AUDIT_FILE")
+	if auditPath == "" {
+		auditPath = "audit.jsonl"
+	}
+	if _, err := audit.Open(auditPath, []byte(os.Getenv("DEPLOYBOT_AUDIT_KEY"))); err != nil {
+		log.Println("Couldn't open audit trail: ", err)
+	}
+
+	adminToken := os.Getenv("DEPLOYBOT_ADMIN_TOKEN")
+	adminAddr := os.Getenv("DEPLOYBOT_ADMIN_ADDR")
+	if adminAddr == "" {
+		adminAddr = ":9090"
+	}
+	notifiers := notifier.NewRegistry()
+	if err := notifiers.Reload(cfg.Snapshot().Notifiers); err != nil {
+		log.Println("Initial notifier validation failed: ", err)
+	}
+
+	var gitSync *configsync.Syncer
+	if repoURL := gitRepoURL; repoURL != "" {
+		interval := 5 * time.Minute
+		if secs, err := strconv.Atoi(os.Getenv("DEPLOYBOT_CONFIG_GIT_INTERVAL_SECONDS")); err == nil && secs > 0 {
+			interval = time.Duration(secs) * time.Second
+		}
+		gitSync = configsync.New(cfg, repoURL, os.Getenv("DEPLOYBOT_CONFIG_GIT_BRANCH"), "config-repo", interval)
+		go gitSync.Start(make(chan struct{}))
+	}
+
+	go func() {
+		admin := adminapi.NewServer(cfg, notifiers, adminToken, gitSync, dispatchCommand)
+		log.Println("Admin API listening on " + adminAddr)
+		if err := http.ListenAndServe(adminAddr, admin.Handler()); err != nil {
+			log.Println("Admin API stopped: ", err)
+		}
+	}()
+
+	if policy, ok := cfg.Snapshot().RetryPolicies["kubernetes"]; ok {
+		controller.SetRetryPolicy(policy)
+	}
+	if policy, ok := cfg.Snapshot().RetryPolicies["mattermost"]; ok {
+		mattermostapi.SetRetryPolicy(policy)
+	}
+	mattermostapi.SetChannelAutoCreate(mattermostapi.ChannelAutoCreate{
+		Enabled: cfg.Snapshot().ChannelAutoCreate.Enabled,
+		Prefix:  cfg.Snapshot().ChannelAutoCreate.Prefix,
+		Purpose: cfg.Snapshot().ChannelAutoCreate.Purpose,
+	})
+	mattermostapi.SetTLSConfig(mattermostapi.TLSConfig{
+		CACertPath:         cfg.Snapshot().MattermostTLS.CACertPath,
+		InsecureSkipVerify: cfg.Snapshot().MattermostTLS.InsecureSkipVerify,
+	})
+	mattermostapi.SetProxyConfig(mattermostapi.ProxyConfig{URL: cfg.Snapshot().ProxyURL})
+	if secs := cfg.Snapshot().CommandTimeoutSeconds; secs > 0 {
+		guard.SetCommandTimeout(time.Duration(secs) * time.Second)
+	}
+	controller.SetEndpointMinReady(cfg.Snapshot().EndpointMinReady)
+	if secs := cfg.Snapshot().ResyncPeriodSeconds; secs > 0 {
+		controller.SetResyncPeriod(time.Duration(secs) * time.Second)
+	}
+	controller.SetRateLimiterConfig(controller.RateLimiterConfig{
+		BaseDelay: time.Duration(cfg.Snapshot().RateLimiterBaseDelayMillis) * time.Millisecond,
+		MaxDelay:  time.Duration(cfg.Snapshot().RateLimiterMaxDelaySeconds) * time.Second,
+		QPS:       cfg.Snapshot().RateLimiterQPS,
+		Burst:     int64(cfg.Snapshot().RateLimiterBurst),
+	})
+	controller.SetAPIClientConfig(controller.APIClientConfig{
+		QPS:   cfg.Snapshot().KubeAPIQPS,
+		Burst: cfg.Snapshot().KubeAPIBurst,
+	})
+	controller.SetWorkerCount(cfg.Snapshot().WorkerCount)
+	controller.SetNotificationTag(notificationTag(cfg.Snapshot()))
+	controller.SetClusterName(cfg.Snapshot().ClusterName)
+	controller.SetDashboardURL(cfg.Snapshot().KubernetesDashboardURL)
+	controller.SetEventEnrichment(cfg.Snapshot().EventEnrichmentEnabled)
+	controller.SetDynamicWatches(dynamicWatchSpecs(cfg.Snapshot().DynamicWatches))
+	controller.SetFieldSelectors(cfg.Snapshot().FieldSelectors)
+	controller.SetNotificationFilters(cfg.Snapshot().NotificationFilters)
+	controller.SetMetricsServerEnabled(cfg.Snapshot().MetricsServerEnabled)
+	controller.SetRegistryCredentialCheckInterval(time.Duration(cfg.Snapshot().RegistryCredentialCheckIntervalSeconds) * time.Second)
+	controller.SetBlackoutWindows(cfg.Snapshot().BlackoutWindows)
+	controller.SetEventReplayWindow(time.Duration(cfg.Snapshot().EventReplayWindowMinutes) * time.Minute)
+	controller.SetMattermostClient(mattermostapi.New("http://localhost:8065", "veli", "12345", "devops", "DevopsBot"))
+	if cmNamespace, cmName := os.Getenv("DEPLOYBOT_CONFIG_CONFIGMAP_NAMESPACE"), os.Getenv("DEPLOYBOT_CONFIG_CONFIGMAP_NAME"); cmNamespace != "" && cmName != "" {
+		controller.SetConfigMapSource(cfg, cmNamespace, cmName)
+	}
+	go controller.Start()
 	mm := &mattermostapi.MatterMost{
 		Url:         "http://localhost:8065",
 		UserName:    "bot",
@@ -103,9 +240,42 @@ func main() {
 		TeamName:    "chatbot",
 		ChannelName: "DevopsBot",
 	}
+	botMatterMost = mm
 
 	client := mm.GetClient()
 
+	monitorManager = monitor.NewManager("monitors.json", func(message string) {
+		mm.PostMessage(client, message)
+	})
+
+	credChecker := credcheck.NewChecker(1*time.Hour, func(message string) {
+		mm.PostMessage(client, message)
+	})
+	credChecker.Add(credcheck.Check{Name: "mattermost-bot", Probe: func() error {
+		_, err := mm.TryGetClient()
+		return err
+	}})
+	credChecker.Add(credcheck.Check{Name: "notifiers", Probe: func() error {
+		for name, err := range notifiers.HealthCheck() {
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil
+	}})
+	credChecker.Add(credcheck.Check{Name: "aws", Probe: func() error {
+		sess, err := session.NewSession(&aws.Config{
+			Region:     aws.String("eu-west-1"),
+			HTTPClient: &http.Client{Transport: &http.Transport{Proxy: mattermostapi.ProxyFunc()}},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		return err
+	}})
+	go credChecker.Start(make(chan struct{}))
+
 	log.Println(" Channle Id in main " + mm.ChannelId)
 	webSocketClient, err := model.NewWebSocketClient4("ws://localhost:8065", client.AuthToken)
 	if err != nil {
@@ -129,34 +299,89 @@ func main() {
 	signal.Notify(sigterm, syscall.SIGINT)
 	<-sigterm
 
+	shutdown(mm, client)
+}
+
+// shutdownDrainTimeout bounds how long shutdown waits for in-flight
+// workqueue items to finish before giving up and exiting anyway.
+const shutdownDrainTimeout = 10 * time.Second
+
+// shutdown runs on SIGTERM/SIGINT: it stops every controller's informer and
+// lets in-flight items drain for up to shutdownDrainTimeout, then posts a
+// final status message so the channel knows DeployBot is about to
+// disconnect rather than having just gone silent.
+func shutdown(mm *mattermostapi.MatterMost, client *model.Client) {
+	log.Println("shutting down: draining controllers")
+	controller.Stop(shutdownDrainTimeout)
+	mm.PostMessage(client, "DeployBot going offline for a restart/deploy - back shortly.")
 }
 
 func HandleResponse(event *model.WebSocketEvent, mc *mattermostapi.MatterMost) {
 
-	//log.Println("HandleResponse - " + event.Broadcast.ChannelId)
-	if event.Broadcast.ChannelId != mc.ChannelId {
+	if event.Event == model.WEBSOCKET_EVENT_REACTION_ADDED {
+		if !mc.IsKnownChannel(event.Broadcast.ChannelId) {
+			return
+		}
+		guard.Protect("reaction-handler", func(m string) { log.Println(m) }, func() { handleReactionAdded(event) })
 		return
 	}
+
 	if event.Event != model.WEBSOCKET_EVENT_POSTED {
 		return
 	}
 
-	//println("responding to message in channel ", mc.ChannelName)
-
-	//log.Println("responding to debugging channel msg")
-
 	postedmessage := model.PostFromJson(strings.NewReader(event.Data["post"].(string)))
+	if postedmessage == nil {
+		return
+	}
 
-	if postedmessage != nil {
+	if postedmessage.UserId == mc.UserId {
+		return
+	}
 
-		if postedmessage.UserId == mc.UserId {
-			return
-		}
+	// DeployBot is two-way in any channel it's known to post in (its own
+	// configured channel, a per-namespace routed channel, a PostTo
+	// destination) - and, beyond those, anywhere it's @mentioned, so a
+	// command typed in an unrelated channel still reaches it.
+	mentioned := strings.Contains(postedmessage.Message, "@"+mc.UserName)
+	if !mc.IsKnownChannel(event.Broadcast.ChannelId) && !mentioned {
+		return
+	}
 
-		ProcessMessage(postedmessage, mc)
+	ProcessMessage(postedmessage, mc)
+}
 
+// notificationTag builds the "[cluster/env]" prefix controller.notify tags
+// every message with, from whichever of ClusterName/Environment are set.
+// Returns "" (no prefix, the original behavior) if neither is configured.
+func notificationTag(cfg config.Config) string {
+	switch {
+	case cfg.ClusterName != "" && cfg.Environment != "":
+		return fmt.Sprintf("[%s/%s]", cfg.ClusterName, cfg.Environment)
+	case cfg.ClusterName != "":
+		return fmt.Sprintf("[%s]", cfg.ClusterName)
+	case cfg.Environment != "":
+		return fmt.Sprintf("[%s]", cfg.Environment)
+	default:
+		return ""
 	}
+}
 
+// dynamicWatchSpecs converts config.DynamicWatch entries into
+// controller.DynamicWatchSpec, the same shape translated into
+// controller.RateLimiterConfig above - main is the only place allowed to
+// know about both config and controller's types.
+func dynamicWatchSpecs(watches []config.DynamicWatch) []controller.DynamicWatchSpec {
+	specs := make([]controller.DynamicWatchSpec, 0, len(watches))
+	for _, w := range watches {
+		specs = append(specs, controller.DynamicWatchSpec{
+			GroupVersion: w.GroupVersion,
+			Resource:     w.Resource,
+			Kind:         w.Kind,
+			Namespaced:   w.Namespaced,
+		})
+	}
+	return specs
 }
 
 func contains(slice []string, item string) bool {
@@ -168,26 +393,117 @@ func contains(slice []string, item string) bool {
 	return ok
 }
 
+// dispatchCommand runs message against DeployBot's `!` command handlers
+// under callerId's identity for authorization and audit, and rootId's
+// thread for handlers (like !verbose) that are scoped to one. It's shared
+// between the Mattermost message loop and the bot-to-bot admin API command
+// endpoint, so a caller using either path gets the same RBAC, audit trail,
+// and guard.Command panic protection a human typing the command would.
+func dispatchCommand(message, callerId, rootId string) (reply string, handled bool) {
+	switch {
+	case strings.HasPrefix(message, "!test-rule"):
+		return guard.Command("test-rule", testRule)(message), true
+	case strings.HasPrefix(message, "!postmortem"):
+		return guard.Command("postmortem", postmortem)(message), true
+	case strings.HasPrefix(message, "!templates"):
+		return guard.Command("templates", templatesCommand)(message), true
+	case strings.HasPrefix(message, "!feedback"):
+		return guard.Command("feedback", feedbackCommand)(message), true
+	case strings.HasPrefix(message, "!rule-stats"):
+		return guard.Command("rule-stats", ruleStatsCommand)(message), true
+	case strings.HasPrefix(message, "!locale"):
+		return guard.Command("locale", func(m string) string { return localeCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!monitor"):
+		return guard.Command("monitor", monitorCommand)(message), true
+	case strings.HasPrefix(message, "!netcheck"):
+		return guard.Command("netcheck", func(m string) string { return netcheckCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!upgrade-watch"):
+		return guard.Command("upgrade-watch", func(m string) string { return upgradeWatchCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!owners"):
+		return guard.Command("owners", ownersCommand)(message), true
+	case strings.HasPrefix(message, "!permissions"):
+		return guard.Command("permissions", permissionsCommand)(message), true
+	case strings.HasPrefix(message, "!whats-wrong"):
+		return guard.Command("whats-wrong", whatsWrongCommand)(message), true
+	case strings.HasPrefix(message, "!debug-pod"):
+		return guard.Command("debug-pod", func(m string) string { return debugPodCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!sudo-confirm"):
+		return guard.Command("sudo-confirm", func(m string) string { return sudoConfirmCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!sudo"):
+		return guard.Command("sudo", func(m string) string { return sudoCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!confirm-delete"):
+		return guard.Command("confirm-delete", func(m string) string { return confirmDeleteCommand(m, callerId) })(message), true
+	case strings.HasPrefix(message, "!verbose"):
+		return guard.Command("verbose", func(m string) string { return verboseCommand(m, rootId) })(message), true
+	case strings.HasPrefix(message, "!deps"):
+		return guard.Command("deps", depsCommand)(message), true
+	case strings.HasPrefix(message, "!resolve"):
+		return guard.Command("resolve", resolveCommand)(message), true
+	case strings.HasPrefix(message, "!resume"):
+		return guard.Command("resume", resumeCommand)(message), true
+	}
+	return "", false
+}
+
 func ProcessMessage(postedmessage *model.Post, mc *mattermostapi.MatterMost) {
 
 	log.Println("Posted message for - " + postedmessage.Message + " by user " + postedmessage.UserId)
+
+	rootId := postedmessage.RootId
+	if rootId == "" {
+		rootId = postedmessage.Id
+	}
+	if reply, handled := dispatchCommand(postedmessage.Message, postedmessage.UserId, rootId); handled {
+		if message, ephemeral := guard.SplitEphemeral(reply); ephemeral {
+			mc.PostEphemeral(mc.GetClient(), postedmessage.UserId, message)
+		} else {
+			mc.PostMessage(mc.GetClient(), reply)
+		}
+		return
+	}
+	if globalConfig.Snapshot().OfflineMode {
+		mc.PostMessage(mc.GetClient(), "DeployBot is running in offline mode - natural-language (Lex) requests are disabled. Use one of the ! commands instead.")
+		return
+	}
+
 	res, _ := mc.GetClient().GetUser(postedmessage.UserId, mc.GetClient().Etag)
 	username := res.Data.(*model.User).GetFullName()
 	input := new(lexruntimeservice.PostTextInput)
-	input.SetBotAlias("devopsbot")
+	input.SetBotAlias(lexBotAlias(postedmessage.UserId))
 	input.SetBotName("devopsbot")
 	input.SetInputText(postedmessage.Message)
 	input.SetUserId(
 		postedmessage.UserId)
 	mySession, _ := session.NewSession(&aws.Config{
-		Region: aws.String("eu-west-1")})
+		Region:     aws.String("eu-west-1"),
+		HTTPClient: &http.Client{Transport: &http.Transport{Proxy: mattermostapi.ProxyFunc()}},
+	})
 
-	output, _ := awslex.GetLexOutput(input, mySession)
+	lexPolicy, ok := globalConfig.Snapshot().RetryPolicies["lex"]
+	if !ok {
+		lexPolicy = retry.DefaultPolicy()
+	}
+	var output *lexruntimeservice.PostTextOutput
+	if err := retry.Do(lexPolicy, func() error {
+		var lexErr error
+		output, lexErr = awslex.GetLexOutput(input, mySession)
+		return lexErr
+	}); err != nil {
+		log.Println("Lex request failed: ", err)
+		mc.PostMessage(mc.GetClient(), "DeployBot couldn't reach Lex - please try again shortly.")
+		return
+	}
 	dialogstate := aws.StringValue(output.DialogState)
 
 	log.Println("Dialog state ", dialogstate)
 	log.Println("message " + aws.StringValue(output.Message))
 	log.Println("message " + aws.StringValue(output.IntentName))
+	guard.Protect("lex-dialog", func(m string) { mc.PostMessage(mc.GetClient(), m) }, func() {
+		handleLexDialog(dialogstate, output, username, mc)
+	})
+}
+
+func handleLexDialog(dialogstate string, output *lexruntimeservice.PostTextOutput, username string, mc *mattermostapi.MatterMost) {
 	switch dialogstate {
 	case "ConfirmIntent":
 
@@ -215,7 +531,12 @@ func ProcessMessage(postedmessage *model.Post, mc *mattermostapi.MatterMost) {
 			if !contains(configuredProjs, appname) {
 				mc.PostMessage(mc.GetClient(), "Invalid application name, please start over and make sure to give correct appname ")
 			} else {
+				environment := aws.StringValue(output.Slots["environment"])
 				mc.PostMessage(mc.GetClient(), aws.StringValue(output.Message))
+				deployLimiter.Acquire(environment, func(position int) {
+					mc.PostMessage(mc.GetClient(), queuePositionMessage(appname, environment, position))
+				})
+				defer deployLimiter.Release(environment)
 				log.Println("Trying to call the jenkins")
 				_ = basicAuth(appname)
 				result, number := basicStatus(appname)
@@ -237,7 +558,10 @@ func ProcessMessage(postedmessage *model.Post, mc *mattermostapi.MatterMost) {
 	case "ReadyForFulfillment":
 		intent := aws.StringValue(output.IntentName)
 		log.Println("intent name " + intent)
-		messageToSend := BackendLogic(output, intent, mc)
+		var messageToSend string
+		guard.Protect("lex-backend-logic", func(m string) { messageToSend = m }, func() {
+			messageToSend = BackendLogic(output, intent, mc)
+		})
 		log.Println("AppName " + aws.StringValue(output.Slots["App"]))
 		mc.PostMessage(mc.GetClient(), username+" - "+messageToSend)
 		break
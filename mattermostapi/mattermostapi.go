@@ -1,11 +1,206 @@
 package mattermostapi
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"DeployBot/retry"
+	"DeployBot/truncate"
 
 	"github.com/mattermost/mattermost-server/model"
 )
 
+// frontendName identifies this package to truncate's per-frontend limits.
+const frontendName = "mattermost"
+
+// retryPolicy controls how post retries a failed CreatePost call, with
+// backoff and jitter, before giving up to the 401 and channel-reroute
+// handling further down - so a transient network blip or a Mattermost
+// restart doesn't lose a deployment notification outright. Left at its zero
+// value (a single attempt, no retries), post behaves exactly as before.
+// Overridden via SetRetryPolicy.
+var retryPolicy retry.Policy
+
+// SetRetryPolicy lets main wire in the "mattermost" entry of
+// config.Config.RetryPolicies, overriding retryPolicy for every post made
+// from this point on.
+func SetRetryPolicy(p retry.Policy) {
+	retryPolicy = p
+}
+
+// ChannelAutoCreate configures resolveChannel to create a missing channel
+// instead of failing the whole login/post with "channel name not
+// available" - useful for a per-namespace or per-environment channel
+// naming scheme that otherwise has to be provisioned by hand before
+// DeployBot can notify into it.
+type ChannelAutoCreate struct {
+	// Enabled turns auto-create on. Left false (the default), a missing
+	// channel behaves exactly as before: resolveChannel fails and
+	// GetClient/TryGetClient's caller sees the error.
+	Enabled bool
+	// Prefix is prepended to ChannelName when creating it, e.g. "ns-" so
+	// auto-created channels are visibly distinct from hand-created ones.
+	// Channels are still looked up and reused by their plain ChannelName
+	// (with the prefix already applied by the caller, if any) - Prefix
+	// only affects the DisplayName of a newly created channel.
+	Prefix string
+	// Purpose is set as the new channel's purpose text.
+	Purpose string
+}
+
+// channelAutoCreate is overridden via SetChannelAutoCreate before any
+// MatterMost value resolves a channel.
+var channelAutoCreate ChannelAutoCreate
+
+// SetChannelAutoCreate overrides channelAutoCreate for every MatterMost
+// value's channel resolution from this point on.
+func SetChannelAutoCreate(cfg ChannelAutoCreate) {
+	channelAutoCreate = cfg
+}
+
+// TLSConfig controls the HTTPS connections this package makes to the
+// Mattermost server and to an incoming webhook, for a server whose
+// certificate Go's standard trusted root CAs don't already cover.
+type TLSConfig struct {
+	// CACertPath, when set, is a PEM file added to the trusted root CAs
+	// for this connection - an internally-signed Mattermost server's CA,
+	// say - instead of requiring the host's CA bundle to already trust it.
+	CACertPath string
+	// InsecureSkipVerify disables certificate verification entirely. It's
+	// an explicit escape hatch for getting unblocked before CACertPath is
+	// set up properly, not a recommended steady state.
+	InsecureSkipVerify bool
+}
+
+// tlsConfig is overridden via SetTLSConfig before any MatterMost value logs
+// in or posts to a webhook.
+var tlsConfig TLSConfig
+
+// SetTLSConfig overrides tlsConfig for every client built from this point
+// on. Already-cached sessions (see session.client) aren't rebuilt, so this
+// needs to be called before the first login.
+func SetTLSConfig(cfg TLSConfig) {
+	tlsConfig = cfg
+}
+
+// ProxyConfig controls what HTTP/HTTPS proxy outbound connections this
+// package makes use.
+type ProxyConfig struct {
+	// URL, when set, is used as the proxy for every outbound connection
+	// this package makes - http://proxy.example.com:3128, say - overriding
+	// the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+	// Left empty (the default), those environment variables are honored
+	// instead, the same as Go's http.DefaultTransport.
+	URL string
+}
+
+// proxyConfig is overridden via SetProxyConfig before any MatterMost value
+// logs in or posts to a webhook.
+var proxyConfig ProxyConfig
+
+// SetProxyConfig overrides proxyConfig for every client built from this
+// point on, the same caveat as SetTLSConfig about already-cached sessions.
+func SetProxyConfig(cfg ProxyConfig) {
+	proxyConfig = cfg
+}
+
+// ProxyFunc resolves proxyConfig into an http.Transport.Proxy function -
+// either a fixed URL, or falling back to http.ProxyFromEnvironment when
+// proxyConfig.URL is unset or fails to parse. Exported so main can build
+// the AWS Lex session's *http.Client the same way, instead of corporate
+// clusters that need a proxy for Mattermost but not for Lex (or the
+// reverse) being left with two different proxy policies to configure.
+func ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if proxyConfig.URL == "" {
+		return http.ProxyFromEnvironment
+	}
+	fixed, err := url.Parse(proxyConfig.URL)
+	if err != nil {
+		log.Println("mattermostapi: invalid proxy URL, falling back to HTTP_PROXY/HTTPS_PROXY/NO_PROXY:", err)
+		return http.ProxyFromEnvironment
+	}
+	return http.ProxyURL(fixed)
+}
+
+// httpClient returns the *http.Client a login or webhook post should use:
+// mm.HTTPClient if the caller supplied one (e.g. a test's
+// vcr.RoundTripper), otherwise one built from tlsConfig/proxyConfig - or
+// just http.DefaultClient if both are also left at their zero values.
+func (mm *MatterMost) httpClient() (*http.Client, error) {
+	if mm.HTTPClient != nil {
+		return mm.HTTPClient, nil
+	}
+	if tlsConfig.CACertPath == "" && !tlsConfig.InsecureSkipVerify && proxyConfig.URL == "" {
+		return http.DefaultClient, nil
+	}
+
+	transport := &http.Transport{
+		Proxy:           ProxyFunc(),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsConfig.InsecureSkipVerify},
+	}
+	if tlsConfig.CACertPath != "" {
+		pem, err := ioutil.ReadFile(tlsConfig.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read CA cert %q: %v", tlsConfig.CACertPath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", tlsConfig.CACertPath)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// session is a MatterMost value's shared, lazily-established login: the
+// first TryGetClient call against any copy of that value logs in once and
+// caches the result here; every later call (and every post) reuses the
+// same authenticated *model.Client and TeamId instead of re-logging in.
+// Held as a pointer so copies made by WithChannel - a new MatterMost
+// value per target channel - still share one session.
+type session struct {
+	mu     sync.Mutex
+	client *model.Client
+	teamId string
+
+	// teamMu, teams, and channels back PostTo's multi-team/multi-channel
+	// fan-out: teams and channels cache team/channel name -> ID lookups
+	// (keyed by team name, and "team/channel" respectively) so repeated
+	// posts to the same destination don't re-resolve it every time. teamMu
+	// serializes PostTo end to end, since it also covers the interval where
+	// client.TeamId is temporarily switched away from mm's own TeamId -
+	// without that, a concurrent ordinary post sharing the same client
+	// could fire with the wrong team set.
+	teamMu   sync.Mutex
+	teams    map[string]string
+	channels map[string]string
+
+	// knownMu and known back IsKnownChannel: every channel ID any copy of
+	// a MatterMost sharing this session has resolved (its own ChannelId,
+	// or a PostTo destination) is recorded here, so the WebSocket listener
+	// can tell a post in "one of DeployBot's channels" apart from a post
+	// in some unrelated channel it's never talked in.
+	knownMu sync.Mutex
+	known   map[string]bool
+}
+
+// newSession builds a session with its lookup caches ready to use.
+func newSession() *session {
+	return &session{
+		teams:    make(map[string]string),
+		channels: make(map[string]string),
+		known:    make(map[string]bool),
+	}
+}
+
 type MatterMost struct {
 	Url         string
 	UserName    string
@@ -15,49 +210,641 @@ type MatterMost struct {
 	TeamName    string
 	ChannelId   string
 	TeamId      string
+	// FallbackChannelName is where post() reroutes once ChannelName can no
+	// longer be resolved - archived or renamed out from under it - instead
+	// of failing every post with a stale ChannelId. Defaults to
+	// "town-square", which every Mattermost team has.
+	FallbackChannelName string
+	// HTTPClient, when set, replaces the *http.Client TryGetClient would
+	// otherwise build fresh for every login - e.g. one wrapping a
+	// vcr.RoundTripper, so login/channel lookup/posting/uploads can be
+	// tested deterministically against a recorded cassette instead of a
+	// live server. Left nil (the default), TryGetClient behaves exactly
+	// as before.
+	HTTPClient *http.Client
+	// WebhookURL, when set, makes this identity post through a Mattermost
+	// incoming webhook instead of logging in as UserName/Password - for a
+	// locked-down server that only allows webhook posting. TryGetClient
+	// skips login entirely and PostMessage delivers straight to
+	// WebhookURL; every other method still expects a real *model.Client
+	// and isn't meaningful for a webhook-only identity.
+	WebhookURL string
+	session    *session
+}
+
+// New builds a MatterMost identity with its own session, established once
+// on first use and reused (thread-safely) by every copy WithChannel makes
+// of it - the long-lived client callers should create once at startup and
+// inject wherever a different target channel is needed, instead of each
+// call site building and logging in its own throwaway MatterMost value.
+func New(url, userName, password, teamName, channelName string) *MatterMost {
+	return &MatterMost{
+		Url:         url,
+		UserName:    userName,
+		Password:    password,
+		TeamName:    teamName,
+		ChannelName: channelName,
+		session:     newSession(),
+	}
+}
+
+// WithChannel returns a copy of mm targeting channelName instead, sharing
+// mm's session (so it doesn't log in again) but resolving its own
+// ChannelId the first time it's used.
+func (mm *MatterMost) WithChannel(channelName string) *MatterMost {
+	clone := *mm
+	clone.ChannelName = channelName
+	clone.ChannelId = ""
+	return &clone
 }
 
 func (mm *MatterMost) GetClient() *model.Client {
+	client, err := mm.TryGetClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return client
+}
+
+// TryGetClient does the same login/team/channel resolution as GetClient but
+// returns an error instead of exiting the process, so callers that need to
+// validate a backend (e.g. before hot-swapping it in) can handle failure
+// themselves. The login itself only happens once per session - every other
+// call (including every one made through a WithChannel copy) reuses the
+// cached *model.Client and only re-resolves the channel, which is cheap and
+// can legitimately differ between copies.
+func (mm *MatterMost) TryGetClient() (*model.Client, error) {
+	if mm.WebhookURL != "" {
+		return nil, nil
+	}
+	client, err := mm.authenticatedClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := mm.resolveChannel(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// authenticatedClient returns mm's shared session client, logging in and
+// resolving the team only the first time any copy of mm needs one.
+func (mm *MatterMost) authenticatedClient() (*model.Client, error) {
+	if mm.session == nil {
+		mm.session = newSession()
+	}
+	mm.session.mu.Lock()
+	defer mm.session.mu.Unlock()
+
+	if mm.session.client != nil {
+		mm.TeamId = mm.session.teamId
+		return mm.session.client, nil
+	}
+
 	client := model.NewClient(mm.Url)
-	r, e := client.Login(mm.UserName, mm.Password)
-	if e != nil {
-		log.Fatal("Couldn't login: ", e)
+	httpClient, err := mm.httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't build TLS client: %v", err)
 	}
-	//log.Printf("Client logged in. Auth Token: %s.", client.AuthToken)
-	user := r.Data.(*model.User)
-	mm.UserId = user.Id
-	//log.Println("User information: %s", user.ToJson())
+	client.HttpClient = httpClient
+
+	r, err := client.Login(mm.UserName, mm.Password)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't login: %v", err)
+	}
+	mm.UserId = r.Data.(*model.User).Id
+
 	team, err := client.GetTeamByName(mm.TeamName)
 	if err != nil {
-		log.Fatal("Team Name not available")
+		return nil, fmt.Errorf("team name not available: %v", err)
 	}
 	mm.TeamId = team.Data.(*model.Team).Id
-	//log.Println(mm.TeamId)
 	client.SetTeamId(mm.TeamId)
 
+	mm.session.client = client
+	mm.session.teamId = mm.TeamId
+	return client, nil
+}
+
+// resolveChannel sets mm.ChannelId from mm.ChannelName, unconditionally -
+// unlike the login, this has to run per MatterMost value (not just once per
+// session) since WithChannel copies legitimately target different channels.
+// If the channel doesn't exist and channelAutoCreate is enabled, it's
+// created (see createChannel) instead of failing the lookup.
+func (mm *MatterMost) resolveChannel(client *model.Client) error {
 	result, err := client.GetChannelByName(mm.ChannelName)
+	if err == nil {
+		mm.ChannelId = result.Data.(*model.Channel).Id
+		mm.rememberChannel(mm.ChannelId)
+		return nil
+	}
+	if !channelAutoCreate.Enabled {
+		return fmt.Errorf("channel name not available: %v", err)
+	}
+
+	created, createErr := mm.createChannel(client)
+	if createErr != nil {
+		return fmt.Errorf("channel name not available, and auto-create failed: %v", createErr)
+	}
+	mm.ChannelId = created.Id
+	mm.rememberChannel(mm.ChannelId)
+	return nil
+}
+
+// rememberChannel records channelId as one of this session's known
+// channels, so IsKnownChannel recognizes it later.
+func (mm *MatterMost) rememberChannel(channelId string) {
+	mm.session.knownMu.Lock()
+	mm.session.known[channelId] = true
+	mm.session.knownMu.Unlock()
+}
+
+// IsKnownChannel reports whether channelId is one this session has
+// resolved before - mm's own ChannelId at some point, or a PostTo
+// destination - for the WebSocket listener to tell apart a post in "one of
+// DeployBot's channels" from a post somewhere it's never talked.
+func (mm *MatterMost) IsKnownChannel(channelId string) bool {
+	mm.session.knownMu.Lock()
+	defer mm.session.knownMu.Unlock()
+	return mm.session.known[channelId]
+}
+
+// createChannel creates mm.ChannelName as a new open channel, for
+// resolveChannel to fall back to when channelAutoCreate is enabled and the
+// channel doesn't exist yet - e.g. the first Deployment notification for a
+// newly onboarded namespace, instead of requiring an admin to provision the
+// channel by hand first.
+func (mm *MatterMost) createChannel(client *model.Client) (*model.Channel, error) {
+	displayName := channelAutoCreate.Prefix + mm.ChannelName
+	r, err := client.CreateChannel(&model.Channel{
+		Name:        mm.ChannelName,
+		DisplayName: displayName,
+		Purpose:     channelAutoCreate.Purpose,
+		Type:        model.CHANNEL_OPEN,
+	})
 	if err != nil {
-		log.Fatal("Channel Name not available")
+		return nil, err
 	}
-	mm.ChannelId = result.Data.(*model.Channel).Id
-	//log.Println("Channle id ", mm.ChannelId)
-	return client
+	log.Printf("mattermostapi: auto-created channel %q", mm.ChannelName)
+	return r.Data.(*model.Channel), nil
+}
+
+func (mm *MatterMost) PostMessage(client *model.Client, messagetosend string) *model.Post {
+	if mm.WebhookURL != "" {
+		return mm.postWebhook(messagetosend)
+	}
+	return mm.post(client, "", messagetosend)
+}
+
+// postWebhook delivers messagetosend to WebhookURL as a bare, unauthenticated
+// JSON POST - Mattermost's incoming-webhook protocol - for PostMessage's
+// webhook-mode path. There's no post data to hand back on success (an
+// incoming webhook's response isn't a model.Post), so it always returns nil;
+// callers that need the created post back can't use webhook mode.
+func (mm *MatterMost) postWebhook(messagetosend string) *model.Post {
+	payload, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: messagetosend})
+	if err != nil {
+		log.Println("mattermostapi: couldn't marshal webhook payload:", err)
+		return nil
+	}
+
+	httpClient, err := mm.httpClient()
+	if err != nil {
+		log.Println("mattermostapi: couldn't build TLS client for webhook post:", err)
+		return nil
+	}
+	resp, err := httpClient.Post(mm.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Println("mattermostapi: couldn't post to webhook:", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Println("mattermostapi: webhook post failed with status", resp.Status)
+	}
+	return nil
+}
+
+// PostReply is PostMessage for a threaded reply: it sets RootId so the post
+// shows up inside an existing thread instead of starting a new one.
+func (mm *MatterMost) PostReply(client *model.Client, rootId, messagetosend string) *model.Post {
+	return mm.post(client, rootId, messagetosend)
+}
+
+func (mm *MatterMost) post(client *model.Client, rootId, messagetosend string) *model.Post {
+	body, fileIds := mm.truncateForPost(client, messagetosend)
+
+	newPost := model.Post{
+		UserId:    mm.UserId,
+		ChannelId: mm.ChannelId,
+		RootId:    rootId,
+		Message:   body,
+		FileIds:   model.StringArray(fileIds),
+	}
+	return mm.sendPost(client, &newPost)
 }
 
-func (mm *MatterMost) PostMessage(client *model.Client, messagetosend string) {
+// EditPost updates an existing post's Message in place - e.g. a rollout
+// progress line going from "3/6 replicas ready" to "6/6 ready" - instead of
+// posting a new message for every change. Returns the updated post, or nil
+// if the update failed (e.g. the post was deleted out from under it);
+// callers should fall back to PostMessage/PostReply rather than treating
+// that as fatal.
+func (mm *MatterMost) EditPost(client *model.Client, postId, messagetosend string) *model.Post {
+	body, fileIds := mm.truncateForPost(client, messagetosend)
+	update := model.Post{
+		Id:        postId,
+		ChannelId: mm.ChannelId,
+		Message:   body,
+		FileIds:   model.StringArray(fileIds),
+	}
+	r, err := client.UpdatePost(&update)
+	if err != nil {
+		log.Println("mattermostapi: couldn't update post", postId, ":", err)
+		return nil
+	}
+	return r.Data.(*model.Post)
+}
+
+// PostEphemeral posts messagetosend to userId only - command errors, help
+// output, and permission denials that would otherwise clutter a shared
+// channel with bot back-and-forth nobody else needs to see. The vendored
+// v3 client has no ephemeral-post helper (it was added to the v4 API), so
+// this calls the "/posts/ephemeral" route directly with a PostEphemeral
+// body shaped the same way client4.CreatePostEphemeral sends it.
+func (mm *MatterMost) PostEphemeral(client *model.Client, userId, messagetosend string) *model.Post {
+	body, fileIds := mm.truncateForPost(client, messagetosend)
+	ephemeral := model.PostEphemeral{
+		UserID: userId,
+		Post: &model.Post{
+			UserId:    mm.UserId,
+			ChannelId: mm.ChannelId,
+			Message:   body,
+			FileIds:   model.StringArray(fileIds),
+		},
+	}
+	r, err := client.DoApiPost("/posts/ephemeral", ephemeral.ToUnsanitizedJson())
+	if err != nil {
+		log.Println("mattermostapi: couldn't post ephemeral message to", userId, ":", err)
+		return nil
+	}
+	defer r.Body.Close()
+	return model.PostFromJson(r.Body)
+}
+
+// PostFile uploads data as filename and attaches it to a new post with
+// messagetosend as the body - e.g. the tail of a crashing Pod's logs,
+// instead of pasting them inline where truncateForPost would just excerpt
+// them anyway. Returns nil if the upload or the post itself fails.
+func (mm *MatterMost) PostFile(client *model.Client, filename string, data []byte, messagetosend string) *model.Post {
+	resp, err := client.UploadPostAttachment(data, mm.ChannelId, filename)
+	if err != nil || len(resp.FileInfos) == 0 {
+		log.Println("mattermostapi: couldn't upload", filename, ":", err)
+		return nil
+	}
 
 	newPost := model.Post{
 		UserId:    mm.UserId,
 		ChannelId: mm.ChannelId,
 		Message:   messagetosend,
+		FileIds:   model.StringArray{resp.FileInfos[0].Id},
+	}
+	return mm.sendPost(client, &newPost)
+}
+
+// AttachmentField is one short key/value pair shown inline in a rich
+// attachment (namespace, image, replicas, cluster, ...). Short fields are
+// laid out side by side instead of stacked, the same as Slack's own
+// attachment rendering Mattermost copies.
+type AttachmentField struct {
+	Title string
+	Value string
+	Short bool
+}
+
+// Attachment is the structured payload PostAttachment turns into a
+// Mattermost/Slack-style message attachment - a colored sidebar (see
+// SeverityColor), a handful of inline Fields, and a Title optionally
+// linking out (e.g. to the Kubernetes dashboard) - instead of everything
+// being squeezed into PostMessage's plain concatenated string.
+type Attachment struct {
+	Color     string
+	Title     string
+	TitleLink string
+	Fields    []AttachmentField
+	// Actions renders as a row of interactive buttons under the
+	// attachment (Approve/Reject/View, say), each POSTing to its own URL
+	// when clicked - see Action.
+	Actions []Action
+}
+
+// Action is one interactive button PostAttachment renders under an
+// Attachment. Url is the action callback endpoint Mattermost's server
+// POSTs a model.PostActionIntegrationRequest to when a user clicks the
+// button; Context is round-tripped back in that request's Context field
+// untouched - callers use it to carry an approval.Stage token identifying
+// which pending request the click resolves.
+type Action struct {
+	Id      string
+	Name    string
+	Url     string
+	Context map[string]interface{}
+}
+
+// severityColor maps a rules.Severity string to a sidebar color.
+// mattermostapi doesn't import the rules package for this one string
+// constant, so callers pass e.Severity's string value straight through.
+var severityColor = map[string]string{
+	"critical": "#D63333",
+	"warning":  "#E0A800",
+	"info":     "#2B8A3E",
+}
+
+// SeverityColor looks up the Attachment.Color for a rules.Severity string,
+// falling back to a neutral gray for an empty or unrecognized value.
+func SeverityColor(severity string) string {
+	if c, ok := severityColor[severity]; ok {
+		return c
+	}
+	return "#8A8A8A"
+}
+
+// PostAttachment is PostMessage for a structured Kubernetes event:
+// messagetosend is still posted as the plain-text fallback (what
+// notifications, unfurling, and push previews show), with a colored,
+// field-laid-out attachment rendered on top of it.
+func (mm *MatterMost) PostAttachment(client *model.Client, rootId, messagetosend string, a Attachment) *model.Post {
+	body, fileIds := mm.truncateForPost(client, messagetosend)
+
+	fields := make([]*model.SlackAttachmentField, 0, len(a.Fields))
+	for _, f := range a.Fields {
+		fields = append(fields, &model.SlackAttachmentField{Title: f.Title, Value: f.Value, Short: f.Short})
+	}
+	actions := make([]*model.PostAction, 0, len(a.Actions))
+	for _, act := range a.Actions {
+		actions = append(actions, &model.PostAction{
+			Id:   act.Id,
+			Name: act.Name,
+			Integration: &model.PostActionIntegration{
+				URL:     act.Url,
+				Context: act.Context,
+			},
+		})
+	}
+
+	newPost := model.Post{
+		UserId:    mm.UserId,
+		ChannelId: mm.ChannelId,
+		RootId:    rootId,
+		Message:   body,
+		FileIds:   model.StringArray(fileIds),
+	}
+	newPost.AddProp("attachments", []*model.SlackAttachment{{
+		Fallback:  body,
+		Color:     a.Color,
+		Title:     a.Title,
+		TitleLink: a.TitleLink,
+		Fields:    fields,
+		Actions:   actions,
+	}})
+	return mm.sendPost(client, &newPost)
+}
+
+// PostTo posts messagetosend to team/channel - a destination that may
+// differ from mm's own TeamName/ChannelName - resolving and caching that
+// team/channel pair the first time it's used, so one login/session can fan
+// a single event out to several teams/channels without building (and
+// logging in with) a separate MatterMost value per destination.
+//
+// Unlike post's channel-reroute fallback (which only applies to mm's own
+// ChannelName/FallbackChannelName), a failed PostTo doesn't retarget
+// anywhere else - there's no single sensible fallback team/channel for an
+// arbitrary destination, so it just logs and returns nil for the caller to
+// skip.
+func (mm *MatterMost) PostTo(client *model.Client, team, channel, messagetosend string) *model.Post {
+	mm.session.teamMu.Lock()
+	defer mm.session.teamMu.Unlock()
+
+	teamId, err := mm.teamIdLocked(client, team)
+	if err != nil {
+		log.Println("mattermostapi: couldn't resolve team", team, ":", err)
+		return nil
+	}
+
+	original := client.TeamId
+	client.SetTeamId(teamId)
+	defer client.SetTeamId(original)
+
+	channelId, err := mm.channelIdOnTeamLocked(client, team, channel)
+	if err != nil {
+		log.Println("mattermostapi: couldn't resolve channel", channel, "on team", team, ":", err)
+		return nil
+	}
+
+	body, fileIds := mm.truncateForPost(client, messagetosend)
+	newPost := model.Post{
+		UserId:    mm.UserId,
+		ChannelId: channelId,
+		Message:   body,
+		FileIds:   model.StringArray(fileIds),
+	}
+	r, e := retryCreatePost(client, &newPost)
+	if e != nil && e.StatusCode == http.StatusUnauthorized {
+		if fresh, authErr := mm.reauthenticate(); authErr == nil {
+			client = fresh
+			client.SetTeamId(teamId)
+			newPost.UserId = mm.UserId
+			r, e = client.CreatePost(&newPost)
+		}
 	}
-	client.Login(mm.UserName, mm.Password)
-	_, e := client.CreatePost(&newPost)
 	if e != nil {
-		log.Fatal("Couldn't make post: ", e)
+		log.Println("mattermostapi: couldn't post to", team, "/", channel, ":", e)
+		return nil
+	}
+	return r.Data.(*model.Post)
+}
+
+// teamIdLocked resolves teamName to a team ID, checking mm.session.teams
+// first. Callers must hold mm.session.teamMu.
+func (mm *MatterMost) teamIdLocked(client *model.Client, teamName string) (string, error) {
+	if id, ok := mm.session.teams[teamName]; ok {
+		return id, nil
+	}
+	team, err := client.GetTeamByName(teamName)
+	if err != nil {
+		return "", fmt.Errorf("team name not available: %v", err)
+	}
+	teamId := team.Data.(*model.Team).Id
+	mm.session.teams[teamName] = teamId
+	return teamId, nil
+}
+
+// channelIdOnTeamLocked resolves channelName to a channel ID on teamName,
+// checking mm.session.channels first. Callers must hold mm.session.teamMu
+// and must already have set client's TeamId to teamName's ID.
+func (mm *MatterMost) channelIdOnTeamLocked(client *model.Client, teamName, channelName string) (string, error) {
+	key := teamName + "/" + channelName
+	if id, ok := mm.session.channels[key]; ok {
+		return id, nil
+	}
+	result, err := client.GetChannelByName(channelName)
+	if err != nil {
+		return "", fmt.Errorf("channel name not available: %v", err)
+	}
+	channelId := result.Data.(*model.Channel).Id
+	mm.session.channels[key] = channelId
+	mm.rememberChannel(channelId)
+	return channelId, nil
+}
+
+// sendPost is post's 401-reauthenticate-and-retry, then
+// channel-reroute-and-retry fallback chain, shared by post and
+// PostAttachment so both honor the same retry/reauth/reroute behavior
+// instead of post's having drifted from a copy-pasted one.
+func (mm *MatterMost) sendPost(client *model.Client, newPost *model.Post) *model.Post {
+	// teamMu keeps this from posting while PostTo has client.TeamId
+	// switched to another team.
+	mm.session.teamMu.Lock()
+	defer mm.session.teamMu.Unlock()
+	r, e := retryCreatePost(client, newPost)
+	if e != nil && e.StatusCode == http.StatusUnauthorized {
+		if fresh, err := mm.reauthenticate(); err == nil {
+			client = fresh
+			newPost.UserId = mm.UserId
+			r, e = client.CreatePost(newPost)
+		}
+	}
+	if e != nil {
+		if !mm.rerouteChannel(client) {
+			log.Println("mattermostapi: couldn't make post and couldn't reroute: ", e)
+			return nil
+		}
+		newPost.ChannelId = mm.ChannelId
+		r, e = client.CreatePost(newPost)
+		if e != nil {
+			log.Println("mattermostapi: couldn't make post even after rerouting: ", e)
+			return nil
+		}
+	}
+	return r.Data.(*model.Post)
+}
+
+// retryCreatePost calls client.CreatePost under retryPolicy, retrying a
+// transient failure with backoff and jitter before post falls through to
+// the 401 and channel-reroute handling, which cover different, non-transient
+// failure modes and so only ever get a single attempt each.
+func retryCreatePost(client *model.Client, post *model.Post) (*model.Result, *model.AppError) {
+	var r *model.Result
+	var e *model.AppError
+	retry.Do(retryPolicy, func() error {
+		r, e = client.CreatePost(post)
+		if e != nil {
+			return e
+		}
+		return nil
+	})
+	return r, e
+}
+
+// reauthenticate drops mm's cached session and logs in again, for post to
+// call once a 401 shows the cached session has expired (idle timeout,
+// server restart, revoked token) - instead of that and every post after it
+// failing until something restarts the whole controller process.
+func (mm *MatterMost) reauthenticate() (*model.Client, error) {
+	if mm.session != nil {
+		mm.session.mu.Lock()
+		mm.session.client = nil
+		mm.session.mu.Unlock()
+	}
+	client, err := mm.authenticatedClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := mm.resolveChannel(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// rerouteChannel re-resolves mm's channel after a post fails, the usual
+// symptom of ChannelId going stale because the channel was archived or
+// renamed. It first retries ChannelName by name (covers a transient
+// lookup failure rather than the channel actually being gone), then falls
+// back to FallbackChannelName ("town-square" if unset) and posts an alert
+// there so admins notice the reroute instead of it happening silently.
+func (mm *MatterMost) rerouteChannel(client *model.Client) bool {
+	if result, err := client.GetChannelByName(mm.ChannelName); err == nil {
+		mm.ChannelId = result.Data.(*model.Channel).Id
+		return true
+	}
+
+	fallback := mm.FallbackChannelName
+	if fallback == "" {
+		fallback = "town-square"
+	}
+	result, err := client.GetChannelByName(fallback)
+	if err != nil {
+		log.Println("mattermostapi: fallback channel", fallback, "also unavailable: ", err)
+		return false
+	}
+
+	log.Printf("mattermostapi: channel %q is no longer reachable (archived or renamed), rerouting to %q", mm.ChannelName, fallback)
+	staleChannel := mm.ChannelName
+	mm.ChannelName = fallback
+	mm.ChannelId = result.Data.(*model.Channel).Id
+	client.CreatePost(&model.Post{
+		UserId:    mm.UserId,
+		ChannelId: mm.ChannelId,
+		Message:   fmt.Sprintf("DeployBot - channel %q is no longer reachable (archived or renamed) - rerouted notifications here.", staleChannel),
+	})
+	return true
+}
+
+// truncateForPost keeps messagetosend under this frontend's message size
+// limit. A message that doesn't fit gets smart-truncated to a head/tail
+// excerpt, with the full content uploaded as a file attachment so nothing
+// is actually lost - just a YAML diff or log excerpt too long to read
+// inline anyway. If the upload itself fails, the truncated excerpt still
+// posts; only the attachment is best-effort.
+func (mm *MatterMost) truncateForPost(client *model.Client, messagetosend string) (string, []string) {
+	excerpt, truncated := truncate.Smart(messagetosend, truncate.Limit(frontendName))
+	if !truncated {
+		return messagetosend, nil
+	}
+
+	resp, err := client.UploadPostAttachment([]byte(messagetosend), mm.ChannelId, "full-output.txt")
+	if err != nil || len(resp.FileInfos) == 0 {
+		return excerpt, nil
+	}
+	return excerpt, []string{resp.FileInfos[0].Id}
+}
+
+// SendDirectMessage posts messagetosend to a direct channel between the
+// bot and userId, creating that channel first if it doesn't already exist.
+// Returns nil if either step fails - e.g. userId has direct messages
+// disabled - so callers can fall back to some other notification path
+// instead of a chat command silently doing nothing.
+func (mm *MatterMost) SendDirectMessage(client *model.Client, userId, messagetosend string) *model.Post {
+	result, err := client.CreateDirectChannel(userId)
+	if err != nil {
+		log.Println("mattermostapi: couldn't create direct channel with", userId, ":", err)
+		return nil
+	}
+	channel := result.Data.(*model.Channel)
+
+	newPost := model.Post{
+		UserId:    mm.UserId,
+		ChannelId: channel.Id,
+		Message:   messagetosend,
+	}
+	r, err := client.CreatePost(&newPost)
+	if err != nil {
+		log.Println("mattermostapi: couldn't DM", userId, ":", err)
+		return nil
 	}
-	//post := r.Data.(*model.Post)
-	//log.Print("Post created: ", post)
+	return r.Data.(*model.Post)
 }
 
 func (mm *MatterMost) GetUserName(userid string, etag string) string {
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+// APIClientMetrics tracks Kubernetes API request outcomes and latency,
+// registered against client-go's tools/metrics hooks so every controller's
+// clientset reports through one shared counter - including 429 ("Too Many
+// Requests") responses, the signal that controller.SetAPIClientConfig's
+// QPS/Burst are tuned too tight for this instance's workload.
+type APIClientMetrics struct {
+	mu         sync.Mutex
+	requests   map[string]int64
+	throttled  int64
+	totalCalls int64
+}
+
+// Default is the process-wide API client metrics tracker, wired into
+// client-go by RegisterDefault.
+var Default = &APIClientMetrics{requests: make(map[string]int64)}
+
+// RegisterDefault wires Default into client-go's request instrumentation
+// hooks. client-go's own Register is itself a sync.Once, so calling this
+// more than once is harmless.
+func RegisterDefault() {
+	clientmetrics.Register(Default, Default)
+}
+
+// Observe implements client-go tools/metrics.LatencyMetric.
+func (m *APIClientMetrics) Observe(verb string, u url.URL, latency time.Duration) {
+	m.mu.Lock()
+	m.totalCalls++
+	m.mu.Unlock()
+}
+
+// Increment implements client-go tools/metrics.ResultMetric, called once
+// per completed request with its response code.
+func (m *APIClientMetrics) Increment(code, method, host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[code]++
+	if code == "429" {
+		m.throttled++
+	}
+}
+
+// APIClientSnapshot is Default's counters at a point in time, for the
+// admin API's /stats endpoint.
+type APIClientSnapshot struct {
+	TotalCalls     int64            `json:"totalCalls"`
+	Throttled      int64            `json:"throttled"`
+	RequestsByCode map[string]int64 `json:"requestsByCode"`
+}
+
+// Snapshot returns m's current counters.
+func (m *APIClientMetrics) Snapshot() APIClientSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byCode := make(map[string]int64, len(m.requests))
+	for code, count := range m.requests {
+		byCode[code] = count
+	}
+	return APIClientSnapshot{TotalCalls: m.totalCalls, Throttled: m.throttled, RequestsByCode: byCode}
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyTracker records end-to-end latency (Kubernetes event timestamp to
+// chat post acknowledgment) per notifier backend and raises an alert when a
+// backend's p95 crosses its configured SLA.
+type LatencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	sla     map[string]time.Duration
+}
+
+// NewLatencyTracker builds a tracker with the given per-backend SLA
+// thresholds. Backends with no entry are tracked but never alert.
+func NewLatencyTracker(sla map[string]time.Duration) *LatencyTracker {
+	return &LatencyTracker{
+		samples: make(map[string][]time.Duration),
+		sla:     sla,
+	}
+}
+
+// Observe records that an event originating at eventTime was acknowledged
+// (posted to chat) at ackTime for the given backend, alerting if the
+// backend's running p95 now exceeds its SLA.
+func (t *LatencyTracker) Observe(backend string, eventTime, ackTime time.Time) {
+	latency := ackTime.Sub(eventTime)
+	if latency < 0 {
+		latency = 0
+	}
+
+	t.mu.Lock()
+	t.samples[backend] = append(t.samples[backend], latency)
+	// Cap per-backend history so long-running processes don't grow this
+	// map without bound.
+	if len(t.samples[backend]) > 1000 {
+		t.samples[backend] = t.samples[backend][len(t.samples[backend])-1000:]
+	}
+	p95 := percentile(t.samples[backend], 0.95)
+	sla, hasSLA := t.sla[backend]
+	t.mu.Unlock()
+
+	if hasSLA && p95 > sla {
+		log.Printf("ALERT: notifier %q p95 latency %s exceeds SLA %s", backend, p95, sla)
+	}
+}
+
+// P95 returns the current p95 latency observed for backend.
+func (t *LatencyTracker) P95(backend string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return percentile(t.samples[backend], 0.95)
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
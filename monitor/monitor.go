@@ -0,0 +1,172 @@
+// Package monitor runs periodic synthetic HTTP checks against URLs
+// registered from chat via `!monitor add`, alerting on failures and
+// latency regressions. Registrations are persisted to disk so they survive
+// a restart.
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// latencyRegressionFactor is how many times slower than a check's first
+// successful response counts as a regression worth alerting on.
+const latencyRegressionFactor = 3
+
+// Check is one registered synthetic uptime check.
+type Check struct {
+	URL      string        `json:"url"`
+	Interval time.Duration `json:"interval"`
+
+	mu       sync.Mutex
+	failing  bool
+	baseline time.Duration
+}
+
+// Manager runs every registered Check on its own interval and persists the
+// registration list to disk.
+type Manager struct {
+	mu     sync.Mutex
+	path   string
+	alert  func(message string)
+	checks map[string]*Check
+	stopCh map[string]chan struct{}
+}
+
+// NewManager loads any checks persisted at path and starts running them,
+// alerting via alert on failures and latency regressions.
+func NewManager(path string, alert func(message string)) *Manager {
+	m := &Manager{path: path, alert: alert, checks: map[string]*Check{}, stopCh: map[string]chan struct{}{}}
+	m.load()
+	for url, c := range m.checks {
+		m.start(url, c)
+	}
+	return m
+}
+
+func (m *Manager) load() {
+	data, err := ioutil.ReadFile(m.path)
+	if err != nil {
+		return
+	}
+	var checks []*Check
+	if err := json.Unmarshal(data, &checks); err != nil {
+		return
+	}
+	for _, c := range checks {
+		m.checks[c.URL] = c
+	}
+}
+
+func (m *Manager) save() error {
+	checks := make([]*Check, 0, len(m.checks))
+	for _, c := range m.checks {
+		checks = append(checks, c)
+	}
+	data, err := json.MarshalIndent(checks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(m.path, data, 0644)
+}
+
+// Add registers url for periodic checking and starts its schedule
+// immediately; the first check runs one interval later.
+func (m *Manager) Add(url string, interval time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.checks[url]; ok {
+		return fmt.Errorf("%s is already monitored", url)
+	}
+	c := &Check{URL: url, Interval: interval}
+	m.checks[url] = c
+	m.start(url, c)
+	return m.save()
+}
+
+// Remove stops and deregisters url.
+func (m *Manager) Remove(url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.checks[url]; !ok {
+		return fmt.Errorf("%s is not monitored", url)
+	}
+	if stop, ok := m.stopCh[url]; ok {
+		close(stop)
+		delete(m.stopCh, url)
+	}
+	delete(m.checks, url)
+	return m.save()
+}
+
+// List returns every registered check's URL and interval.
+func (m *Manager) List() []Check {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Check, 0, len(m.checks))
+	for _, c := range m.checks {
+		out = append(out, Check{URL: c.URL, Interval: c.Interval})
+	}
+	return out
+}
+
+func (m *Manager) start(url string, c *Check) {
+	stop := make(chan struct{})
+	m.stopCh[url] = stop
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.runCheck(c)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) runCheck(c *Check) {
+	start := time.Now()
+	resp, err := http.Get(c.URL)
+	elapsed := time.Since(start)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil || resp.StatusCode >= 500 {
+		if !c.failing {
+			c.failing = true
+			m.alert(fmt.Sprintf("DeployBot - synthetic check failed for %s: %s", c.URL, failureReason(err, resp)))
+		}
+		return
+	}
+
+	if c.failing {
+		c.failing = false
+		m.alert(fmt.Sprintf("DeployBot - synthetic check for %s recovered", c.URL))
+	}
+
+	if c.baseline == 0 {
+		c.baseline = elapsed
+		return
+	}
+	if elapsed > c.baseline*latencyRegressionFactor {
+		m.alert(fmt.Sprintf("DeployBot - synthetic check for %s is slow: %s (baseline %s)", c.URL, elapsed, c.baseline))
+	}
+}
+
+func failureReason(err error, resp *http.Response) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// monitorCommand implements `!monitor add/list/remove`, managing the
+// synthetic uptime checks DeployBot runs against registered URLs.
+func monitorCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) < 2 {
+		return guard.Ephemeral("Usage: !monitor add <url> <interval> | !monitor list | !monitor remove <url>")
+	}
+
+	switch fields[1] {
+	case "add":
+		if len(fields) != 4 {
+			return guard.Ephemeral("Usage: !monitor add <url> <interval>")
+		}
+		interval, err := time.ParseDuration(fields[3])
+		if err != nil {
+			return "Invalid interval: " + err.Error()
+		}
+		if err := monitorManager.Add(fields[2], interval); err != nil {
+			return "DeployBot - " + err.Error()
+		}
+		return fmt.Sprintf("DeployBot - now monitoring %s every %s", fields[2], interval)
+
+	case "remove":
+		if len(fields) != 3 {
+			return guard.Ephemeral("Usage: !monitor remove <url>")
+		}
+		if err := monitorManager.Remove(fields[2]); err != nil {
+			return "DeployBot - " + err.Error()
+		}
+		return "DeployBot - stopped monitoring " + fields[2]
+
+	case "list":
+		checks := monitorManager.List()
+		if len(checks) == 0 {
+			return "No synthetic checks registered."
+		}
+		lines := make([]string, 0, len(checks))
+		for _, c := range checks {
+			lines = append(lines, fmt.Sprintf("- %s every %s", c.URL, c.Interval))
+		}
+		return strings.Join(lines, "\n")
+
+	default:
+		return guard.Ephemeral("Usage: !monitor add <url> <interval> | !monitor list | !monitor remove <url>")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"DeployBot/audit"
+	"DeployBot/controller"
+	"DeployBot/guard"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// netcheckPodTimeout bounds how long !netcheck waits for its debug Pod to
+// finish before giving up.
+const netcheckPodTimeout = 30 * time.Second
+
+// netcheckTargetPattern restricts !netcheck's target to a bare hostname or
+// host:port, since it's interpolated into the debug Pod's shell command.
+var netcheckTargetPattern = regexp.MustCompile(`^[a-zA-Z0-9.\-]+(:[0-9]+)?$`)
+
+// netcheckCommand implements `!netcheck <namespace> <target>`, restricted
+// via authorized() since it creates a Pod. It runs a short-lived
+// busybox Pod in namespace performing a DNS lookup, TCP connect, and HTTP
+// probe against target, returns the Pod's logs, and deletes it.
+func netcheckCommand(message, userId string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 3 {
+		return guard.Ephemeral("Usage: !netcheck <namespace> <target>")
+	}
+	namespace, target := fields[1], fields[2]
+	if !netcheckTargetPattern.MatchString(target) {
+		return "DeployBot - target must look like host or host:port"
+	}
+	if !authorized(userId, "netcheck", namespace+"/"+target) {
+		return guard.Ephemeral("DeployBot - !netcheck is restricted to admins.")
+	}
+	if err := audit.Default.Append(userId, "netcheck", namespace, target); err != nil {
+		fmt.Println("audit: failed to record netcheck:", err)
+	}
+
+	client := controller.Client()
+	if client == nil {
+		return "DeployBot - Kubernetes client isn't available yet."
+	}
+
+	host, port := target, "80"
+	if idx := strings.LastIndex(target, ":"); idx >= 0 {
+		host, port = target[:idx], target[idx+1:]
+	}
+
+	podName := fmt.Sprintf("deploybot-netcheck-%d", time.Now().UnixNano())
+	script := fmt.Sprintf(
+		"echo DNS:; nslookup %s; echo TCP:; nc -zv -w 3 %s %s 2>&1; echo HTTP:; wget -q -T 5 -O- http://%s 2>&1 | head -c 500",
+		host, host, port, target,
+	)
+
+	pod := &api_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: podName, Namespace: namespace},
+		Spec: api_v1.PodSpec{
+			RestartPolicy: api_v1.RestartPolicyNever,
+			Containers: []api_v1.Container{
+				{
+					Name:    "netcheck",
+					Image:   "busybox",
+					Command: []string{"sh", "-c", script},
+				},
+			},
+		},
+	}
+
+	if _, err := client.CoreV1().Pods(namespace).Create(pod); err != nil {
+		return fmt.Sprintf("DeployBot - couldn't create netcheck pod: %v", err)
+	}
+	defer client.CoreV1().Pods(namespace).Delete(podName, &meta_v1.DeleteOptions{})
+
+	if err := waitForPodCompletion(client, namespace, podName, netcheckPodTimeout); err != nil {
+		return fmt.Sprintf("DeployBot - netcheck pod didn't finish: %v", err)
+	}
+
+	logs, err := client.CoreV1().Pods(namespace).GetLogs(podName, &api_v1.PodLogOptions{}).DoRaw()
+	if err != nil {
+		return fmt.Sprintf("DeployBot - couldn't read netcheck logs: %v", err)
+	}
+
+	return fmt.Sprintf("DeployBot - netcheck %s in %s:\n%s", target, namespace, string(logs))
+}
+
+// waitForPodCompletion polls name in namespace until it reaches a terminal
+// phase or timeout elapses.
+func waitForPodCompletion(client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		pod, err := client.CoreV1().Pods(namespace).Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if pod.Status.Phase == api_v1.PodSucceeded || pod.Status.Phase == api_v1.PodFailed {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("timed out waiting for pod to finish")
+}
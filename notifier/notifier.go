@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"DeployBot/mattermostapi"
+	"DeployBot/retry"
+	"fmt"
+	"sync"
+)
+
+// Backend is one configured notification target - a Mattermost team/channel
+// the bot can post to, identified by name so rules and config can refer to
+// it (e.g. "ops", "prod-releases").
+type Backend struct {
+	Name        string
+	Url         string
+	UserName    string
+	Password    string
+	TeamName    string
+	ChannelName string
+	// WebhookURL, when set, has this backend post through a Mattermost
+	// incoming webhook instead of logging in as UserName/Password - no
+	// standing user session needed, so it keeps working against a
+	// locked-down server that only allows webhook posting. UserName,
+	// Password, TeamName, and ChannelName are ignored when this is set,
+	// since an incoming webhook's destination is already baked into its
+	// own URL.
+	WebhookURL string
+	// RetryPolicy controls how hard Reload retries this backend's login
+	// before giving up on it. The zero value means retry.DefaultPolicy().
+	// Unused for a WebhookURL backend, which has no login to retry.
+	RetryPolicy retry.Policy
+}
+
+// Registry holds the live set of notifier backends and can be swapped out
+// wholesale at runtime (config reload / admin API) without restarting the
+// informers that feed it.
+type Registry struct {
+	mu       sync.RWMutex
+	backends map[string]*mattermostapi.MatterMost
+}
+
+// NewRegistry builds an empty notifier registry.
+func NewRegistry() *Registry {
+	return &Registry{backends: make(map[string]*mattermostapi.MatterMost)}
+}
+
+// Get returns the live backend for name, if any.
+func (r *Registry) Get(name string) (*mattermostapi.MatterMost, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mm, ok := r.backends[name]
+	return mm, ok
+}
+
+// Reload validates every backend in configs (logging in and resolving its
+// channel) before atomically swapping the registry's contents - a bad
+// config never takes down notifiers that were already working.
+func (r *Registry) Reload(configs []Backend) error {
+	next := make(map[string]*mattermostapi.MatterMost, len(configs))
+	for _, b := range configs {
+		mm := &mattermostapi.MatterMost{
+			Url:         b.Url,
+			UserName:    b.UserName,
+			Password:    b.Password,
+			TeamName:    b.TeamName,
+			ChannelName: b.ChannelName,
+			WebhookURL:  b.WebhookURL,
+		}
+		policy := b.RetryPolicy
+		if policy == (retry.Policy{}) {
+			policy = retry.DefaultPolicy()
+		}
+		if err := retry.Do(policy, func() error {
+			_, err := mm.TryGetClient()
+			return err
+		}); err != nil {
+			return fmt.Errorf("notifier %q failed validation: %v", b.Name, err)
+		}
+		next[b.Name] = mm
+	}
+
+	r.mu.Lock()
+	r.backends = next
+	r.mu.Unlock()
+	return nil
+}
+
+// HealthCheck re-validates every registered backend's credentials by
+// logging in again, returning the resulting error (nil on success) keyed
+// by backend name - used by credcheck to catch a credential that was
+// rotated outside of DeployBot's own config.
+func (r *Registry) HealthCheck() map[string]error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]error, len(r.backends))
+	for name, mm := range r.backends {
+		_, err := mm.TryGetClient()
+		out[name] = err
+	}
+	return out
+}
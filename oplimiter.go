@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// opLimiter caps how many bot-driven mutations (deploys, rollouts, etc.) can
+// run concurrently per namespace/environment, queuing the rest and letting
+// callers report a requester's position while they wait.
+type opLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	running map[string]int
+	waiters map[string][]chan int
+}
+
+func newOpLimiter(limit int) *opLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return &opLimiter{
+		limit:   limit,
+		running: make(map[string]int),
+		waiters: make(map[string][]chan int),
+	}
+}
+
+// Acquire blocks until a slot for key (usually "<environment>" or
+// "<appname>/<environment>") is available. If it has to wait, onQueued is
+// called once with the requester's 1-based queue position; moving up the
+// queue after that isn't reported.
+func (l *opLimiter) Acquire(key string, onQueued func(position int)) {
+	l.mu.Lock()
+	if l.running[key] < l.limit {
+		l.running[key]++
+		l.mu.Unlock()
+		return
+	}
+
+	wait := make(chan int, 1)
+	l.waiters[key] = append(l.waiters[key], wait)
+	position := len(l.waiters[key])
+	l.mu.Unlock()
+
+	if onQueued != nil {
+		onQueued(position)
+	}
+	// Release hands this waiter its slot directly, already counted in
+	// running.
+	<-wait
+}
+
+// Release gives up a slot for key: it passes straight to the next queued
+// waiter if any, otherwise it's freed.
+func (l *opLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	waiters := l.waiters[key]
+	if len(waiters) == 0 {
+		if l.running[key] > 0 {
+			l.running[key]--
+		}
+		return
+	}
+	// Not freeing the slot in between keeps a new Acquire from taking it
+	// first and pushing running past limit.
+	next := waiters[0]
+	l.waiters[key] = waiters[1:]
+	next <- 1
+}
+
+func queuePositionMessage(appname, environment string, position int) string {
+	return fmt.Sprintf("Queued deploy for %s/%s - position %d in line, will run once earlier operations finish", appname, environment, position)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"DeployBot/controller"
+	"DeployBot/guard"
+	"fmt"
+	"strings"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ownersCommand implements `!owners <namespace>/<pod>`, walking
+// ownerReferences up from a Pod through its ReplicaSet and Deployment, and
+// noting the Helm release or Argo CD Application managing it, if any - so
+// the user can find the object to actually act on instead of the Pod.
+func ownersCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 || !strings.Contains(fields[1], "/") {
+		return guard.Ephemeral("Usage: !owners <namespace>/<pod>")
+	}
+
+	client := controller.Client()
+	if client == nil {
+		return "DeployBot - Kubernetes client isn't available yet."
+	}
+
+	parts := strings.SplitN(fields[1], "/", 2)
+	namespace, podName := parts[0], parts[1]
+
+	pod, err := client.CoreV1().Pods(namespace).Get(podName, meta_v1.GetOptions{})
+	if err != nil {
+		return fmt.Sprintf("DeployBot - couldn't get pod %s/%s: %v", namespace, podName, err)
+	}
+	chain := []string{"Pod/" + podName}
+
+	rsRef, ok := controllerOwner(pod.OwnerReferences)
+	if !ok || rsRef.Kind != "ReplicaSet" {
+		return "DeployBot - ownership chain: " + strings.Join(chain, " -> ")
+	}
+	chain = append(chain, "ReplicaSet/"+rsRef.Name)
+
+	rs, err := client.AppsV1().ReplicaSets(namespace).Get(rsRef.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return "DeployBot - ownership chain: " + strings.Join(chain, " -> ") + fmt.Sprintf(" (couldn't resolve further: %v)", err)
+	}
+	appendRelease(&chain, rs.Annotations, rs.Labels)
+
+	depRef, ok := controllerOwner(rs.OwnerReferences)
+	if !ok || depRef.Kind != "Deployment" {
+		return "DeployBot - ownership chain: " + strings.Join(chain, " -> ")
+	}
+	chain = append(chain, "Deployment/"+depRef.Name)
+
+	dep, err := client.AppsV1().Deployments(namespace).Get(depRef.Name, meta_v1.GetOptions{})
+	if err == nil {
+		appendRelease(&chain, dep.Annotations, dep.Labels)
+	}
+
+	return "DeployBot - ownership chain: " + strings.Join(chain, " -> ")
+}
+
+// controllerOwner returns the controlling OwnerReference (Controller == true)
+// if one is set, falling back to the first reference otherwise.
+func controllerOwner(refs []meta_v1.OwnerReference) (meta_v1.OwnerReference, bool) {
+	for _, r := range refs {
+		if r.Controller != nil && *r.Controller {
+			return r, true
+		}
+	}
+	if len(refs) > 0 {
+		return refs[0], true
+	}
+	return meta_v1.OwnerReference{}, false
+}
+
+// appendRelease appends the Helm release or Argo CD Application managing an
+// object to chain, if either is recorded via their conventional
+// annotation/label.
+func appendRelease(chain *[]string, annotations, labels map[string]string) {
+	if release, ok := annotations["meta.helm.sh/release-name"]; ok {
+		*chain = append(*chain, "Helm release/"+release)
+	}
+	if app, ok := labels["argocd.argoproj.io/instance"]; ok {
+		*chain = append(*chain, "Argo Application/"+app)
+	}
+}
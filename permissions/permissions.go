@@ -0,0 +1,123 @@
+// Package permissions runs startup (and on-demand, via `!permissions`)
+// SelfSubjectAccessReview checks for every verb/resource DeployBot's
+// features need, so missing RBAC shows up as a clear "this command won't
+// work" report instead of a cryptic Forbidden error the first time someone
+// runs it.
+package permissions
+
+import (
+	"fmt"
+	"strings"
+
+	authz_v1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Check is one verb/resource a DeployBot feature needs. Namespace left
+// empty means cluster-wide, matching how every watcher in this tree lists
+// across meta_v1.NamespaceAll.
+type Check struct {
+	Feature     string
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Namespace   string
+}
+
+// Required lists the verb/resource every watcher and privileged command in
+// this tree depends on - one entry per feature, not per watcher, since
+// several watchers share the same underlying RBAC grant in a typical
+// ClusterRole (e.g. "list pods" covers both the Pod watcher and
+// !netcheck's read path).
+var Required = []Check{
+	{Feature: "Pod/Event/Node/PV/PVC/ConfigMap/Secret watchers", Verb: "list", Resource: "pods"},
+	{Feature: "Deployment/StatefulSet/DaemonSet/Job watchers", Verb: "list", Group: "apps", Resource: "deployments"},
+	{Feature: "Job watcher (reads owned Pods)", Verb: "list", Group: "batch", Resource: "jobs"},
+	{Feature: "Service/Ingress watchers", Verb: "list", Group: "extensions", Resource: "ingresses"},
+	{Feature: "PodDisruptionBudget watcher", Verb: "list", Group: "policy", Resource: "poddisruptionbudgets"},
+	{Feature: "Helm chart upgrade detection", Verb: "list", Resource: "secrets"},
+	{Feature: "!netcheck (spawns a debug Pod)", Verb: "create", Resource: "pods"},
+	{Feature: "!netcheck / !debug-pod (reads Pod logs)", Verb: "get", Resource: "pods", Subresource: "log"},
+	{Feature: "!debug-pod (execs into a Pod)", Verb: "create", Resource: "pods", Subresource: "exec"},
+	{Feature: "upgrade-watch / node cordon tracking", Verb: "get", Resource: "nodes"},
+}
+
+// Result is one Check's outcome.
+type Result struct {
+	Check   Check
+	Allowed bool
+	Reason  string
+}
+
+// Run performs a SelfSubjectAccessReview against client for every entry in
+// Required, returning one Result per check in the same order. A review
+// call that itself errors (e.g. the apiserver is unreachable) is recorded
+// as not allowed, with the error as its Reason, rather than aborting the
+// rest of the checks.
+func Run(client kubernetes.Interface) []Result {
+	results := make([]Result, 0, len(Required))
+	for _, check := range Required {
+		results = append(results, runCheck(client, check))
+	}
+	return results
+}
+
+func runCheck(client kubernetes.Interface, check Check) Result {
+	review := &authz_v1.SelfSubjectAccessReview{
+		Spec: authz_v1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authz_v1.ResourceAttributes{
+				Namespace:   check.Namespace,
+				Verb:        check.Verb,
+				Group:       check.Group,
+				Resource:    check.Resource,
+				Subresource: check.Subresource,
+			},
+		},
+	}
+
+	result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		return Result{Check: check, Allowed: false, Reason: err.Error()}
+	}
+	return Result{Check: check, Allowed: result.Status.Allowed, Reason: result.Status.Reason}
+}
+
+// Report renders results as a human-readable summary for `!permissions`
+// and the startup log, listing every denied check grouped under the
+// feature it breaks so an operator can tell which ClusterRole rule to add
+// without cross-referencing this package's source.
+func Report(results []Result) string {
+	var denied []Result
+	for _, r := range results {
+		if !r.Allowed {
+			denied = append(denied, r)
+		}
+	}
+
+	if len(denied) == 0 {
+		return fmt.Sprintf("DeployBot - permissions OK: all %d required verb/resource checks are allowed.", len(results))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "DeployBot - permissions: %d/%d required checks denied, the following will not work:\n", len(denied), len(results))
+	for _, r := range denied {
+		fmt.Fprintf(&b, "- %s: missing %s", r.Check.Feature, verbResource(r.Check))
+		if r.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", r.Reason)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func verbResource(c Check) string {
+	resource := c.Resource
+	if c.Subresource != "" {
+		resource += "/" + c.Subresource
+	}
+	if c.Group != "" {
+		resource += "." + c.Group
+	}
+	return fmt.Sprintf("%q on %q", c.Verb, resource)
+}
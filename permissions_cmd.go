@@ -0,0 +1,14 @@
+package main
+
+import "DeployBot/controller"
+
+// permissionsCommand implements `!permissions`, re-surfacing the startup
+// RBAC preflight's report - which commands and watchers will fail for
+// missing ClusterRole rules - without needing a restart to see it again.
+func permissionsCommand(message string) string {
+	report := controller.PermissionsReport()
+	if report == "" {
+		return "DeployBot - no permissions report yet; the controller hasn't finished starting."
+	}
+	return report
+}
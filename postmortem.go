@@ -0,0 +1,46 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"DeployBot/incident"
+	"DeployBot/resolution"
+	"fmt"
+	"strings"
+)
+
+// postmortem implements the `!postmortem <incident-id>` chat command,
+// bundling every recorded timeline entry for a namespace or resource name,
+// plus every !resolve summary recorded for it, into a Markdown report.
+//
+// The report is posted to the channel as a Markdown message, not uploaded
+// as a file of its own, and isn't committed to a Git repo.
+func postmortem(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 {
+		return guard.Ephemeral("Usage: !postmortem <incident-id>")
+	}
+	id := fields[1]
+
+	records := incident.DefaultLog.Find(id)
+	if len(records) == 0 {
+		return "No recorded timeline entries for " + id
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Postmortem: %s\n\n", id)
+	fmt.Fprintf(&b, "%d timeline entries\n\n", len(records))
+	for _, r := range records {
+		fmt.Fprintf(&b, "- %s **%s/%s** %s: %s\n", r.Time.Format("2006-01-02 15:04:05"), r.Kind, r.Name, r.Reason, r.Message)
+	}
+	if resolved := resolution.Default.Find(id); len(resolved) > 0 {
+		b.WriteString("\n### Resolutions\n\n")
+		for _, r := range resolved {
+			fmt.Fprintf(&b, "- %s **%s/%s** root cause: %s, open for %s", r.ResolvedAt.Format("2006-01-02 15:04:05"), r.Namespace, r.Name, r.RootCause, r.Duration)
+			if r.Actions != "" {
+				fmt.Fprintf(&b, ", actions: %s", r.Actions)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
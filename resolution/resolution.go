@@ -0,0 +1,85 @@
+// Package resolution keeps a bounded history of how alert threads were
+// closed out - how long each stayed open, what root cause it was tagged
+// with, and what actions were taken - so `!postmortem`/reports can show
+// categorized outcomes instead of just a raw event timeline.
+package resolution
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRecords bounds the history so a long-running process doesn't grow
+// memory without bound.
+const maxRecords = 2000
+
+// Record is one resolved alert thread's archival summary.
+type Record struct {
+	CorrelationID string
+	Category      string
+	Namespace     string
+	Name          string
+	RootCause     string
+	Actions       string
+	OpenedAt      time.Time
+	ResolvedAt    time.Time
+	Duration      time.Duration
+}
+
+// Store is a bounded, append-only history of Records, safe for concurrent
+// use.
+type Store struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// Default is the process-wide history controller.Resolve appends to and
+// chat commands read from.
+var Default = New(maxRecords)
+
+// New builds a Store that keeps at most capacity records, dropping the
+// oldest once full.
+func New(capacity int) *Store {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Store{records: make([]Record, 0, capacity)}
+}
+
+// Append adds r to the history, evicting the oldest record if the store is
+// already at capacity.
+func (s *Store) Append(r Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) >= cap(s.records) {
+		s.records = append(s.records[1:], r)
+		return
+	}
+	s.records = append(s.records, r)
+}
+
+// Find returns every resolution recorded for namespace or name id, oldest
+// first.
+func (s *Store) Find(id string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []Record
+	for _, r := range s.records {
+		if r.Namespace == id || r.Name == id {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// RootCauseTally counts resolutions by RootCause, for a report that wants
+// to show which root causes are recurring.
+func (s *Store) RootCauseTally() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tally := make(map[string]int)
+	for _, r := range s.records {
+		tally[r.RootCause]++
+	}
+	return tally
+}
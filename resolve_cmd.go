@@ -0,0 +1,27 @@
+package main
+
+import (
+	"DeployBot/controller"
+	"DeployBot/guard"
+	"fmt"
+	"strings"
+)
+
+// resolveCommand implements `!resolve <ref> <root-cause-tag> [actions
+// taken...]`, closing out the alert notification tagged with correlation ID
+// ref: it posts a structured summary (duration, root cause, actions) into
+// the alert's own thread and records it for !postmortem and reports to use.
+func resolveCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) < 3 {
+		return guard.Ephemeral("Usage: !resolve <ref> <root-cause-tag> [actions taken...]")
+	}
+	ref, rootCause := fields[1], fields[2]
+	actions := strings.Join(fields[3:], " ")
+
+	r, err := controller.Resolve(ref, rootCause, actions)
+	if err != nil {
+		return "DeployBot - " + err.Error()
+	}
+	return fmt.Sprintf("DeployBot - resolved %s (root cause: %s, open for %s)", r.CorrelationID, r.RootCause, r.Duration)
+}
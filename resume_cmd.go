@@ -0,0 +1,25 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"fmt"
+	"strings"
+)
+
+// resumeCommand implements `!resume <token>`, fetching the eventual result
+// of a command guard.Command timed out on instead of leaving the requester
+// with no way back to it.
+func resumeCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 {
+		return guard.Ephemeral("Usage: !resume <token>")
+	}
+	result, done, found := guard.Resume(fields[1])
+	if !found {
+		return fmt.Sprintf("DeployBot - no pending command found for %s.", fields[1])
+	}
+	if !done {
+		return fmt.Sprintf("DeployBot - %s is still running, check back shortly.", fields[1])
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+// Package retry runs a function with a configurable retry/backoff/timeout
+// policy, so each integration (Kubernetes API calls, a notifier, Lex,
+// webhook receivers) can be tuned independently instead of sharing one
+// hardcoded retry count.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Policy configures how a fallible call should be retried.
+type Policy struct {
+	MaxRetries     int           `json:"maxRetries"`
+	InitialBackoff time.Duration `json:"initialBackoff"`
+	MaxBackoff     time.Duration `json:"maxBackoff"`
+	Timeout        time.Duration `json:"timeout"`
+	// Jitter adds a random extra delay in [0, Jitter) on top of each
+	// backoff, so a pile of callers retrying the same failure (e.g. every
+	// DeployBot after a Mattermost restart) don't all wake up and retry in
+	// lockstep. Zero, the default, adds no jitter - existing callers are
+	// unaffected unless they opt in.
+	Jitter time.Duration `json:"jitter"`
+}
+
+// DefaultPolicy mirrors the controller's old hardcoded maxRetries = 5, with
+// no per-attempt timeout and a modest exponential backoff.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxRetries:     5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+// Do calls fn, retrying with exponential backoff (capped at MaxBackoff)
+// until it succeeds, MaxRetries is exhausted, or Timeout elapses. Timeout
+// of zero means no overall deadline.
+func Do(p Policy, fn func() error) error {
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if p.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultPolicy().InitialBackoff
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+		if attempt == p.MaxRetries {
+			break
+		}
+
+		delay := backoff
+		if p.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(p.Jitter)))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("retry timed out after %d attempt(s): %v", attempt+1, lastErr)
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+	return fmt.Errorf("gave up after %d attempt(s): %v", p.MaxRetries+1, lastErr)
+}
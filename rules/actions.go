@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"DeployBot/lru"
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// dedupCacheSize bounds how many recent rule/event/action combinations
+// Dispatcher remembers for deduplication, so a noisy rule firing the same
+// action over and over can't grow memory without bound.
+const dedupCacheSize = 2048
+
+// Dispatcher runs the fan-out Actions attached to a matched Rule. Notify
+// still happens separately for the plain chat message - Dispatcher only
+// covers the extra actions (webhook, ticket, runbook).
+type Dispatcher struct {
+	HTTPClient *http.Client
+	seen       *lru.Cache
+}
+
+// NewDispatcher builds a Dispatcher using http.DefaultClient.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{HTTPClient: http.DefaultClient, seen: lru.New(dedupCacheSize)}
+}
+
+// Metrics reports occupancy/eviction counts for the dispatcher's
+// dedup cache, surfaced by the admin API.
+func (d *Dispatcher) Metrics() lru.Metrics {
+	return d.seen.Metrics()
+}
+
+// Run executes every action attached to rule for the given event, skipping
+// an action if the identical rule/event/action combination already ran
+// recently, then logging (rather than failing the whole batch) on
+// individual action errors.
+func (d *Dispatcher) Run(rule Rule, e Event) {
+	for _, action := range rule.Actions {
+		dedupKey := rule.Name + "|" + string(action.Type) + "|" + action.Target + "|" + e.Namespace + "/" + e.Kind + "/" + e.Name + "/" + e.Reason
+		if _, ok := d.seen.Get(dedupKey); ok {
+			continue
+		}
+		d.seen.Set(dedupKey, struct{}{})
+
+		switch action.Type {
+		case ActionWebhook:
+			if err := d.callWebhook(action.Target, rule, e); err != nil {
+				log.Println("rules: webhook action failed for rule "+rule.Name+": ", err)
+			}
+		case ActionTicket:
+			log.Println("rules: would create ticket in " + action.Target + " for rule " + rule.Name)
+		case ActionRunbook:
+			log.Println("rules: would run runbook " + action.Target + " for rule " + rule.Name)
+		default:
+			log.Println("rules: unknown action type " + string(action.Type) + " on rule " + rule.Name)
+		}
+	}
+}
+
+func (d *Dispatcher) callWebhook(url string, rule Rule, e Event) error {
+	payload, err := json.Marshal(struct {
+		Rule  string `json:"rule"`
+		Event Event  `json:"event"`
+	}{Rule: rule.Name, Event: e})
+	if err != nil {
+		return err
+	}
+	resp, err := d.HTTPClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,57 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EvalExpr is evalExpr, exported for callers outside this package - the
+// controller's notification filter stage - that want to reuse this same
+// narrow expression language instead of parsing their own.
+func EvalExpr(expr string, e Event) (bool, error) {
+	return evalExpr(expr, e)
+}
+
+// evalExpr evaluates a small CEL-inspired expression language over an
+// Event's fields, e.g. `Namespace == "prod" && Kind != "Pod"`.
+//
+// This is intentionally a narrow subset (==, !=, && only) rather than a
+// real CEL/expr-lang integration - pulling in google/cel-go or
+// antonmedv/expr would mean vendoring a new dependency, which this
+// package avoids for now. It covers the common "field equals/not-equals"
+// rule shapes; anything fancier should go through Condition.Op = "regex"
+// or be proposed as a vendored CEL upgrade later.
+func evalExpr(expr string, e Event) (bool, error) {
+	clauses := strings.Split(expr, "&&")
+	for _, clause := range clauses {
+		ok, err := evalClause(strings.TrimSpace(clause), e)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func evalClause(clause string, e Event) (bool, error) {
+	op := "=="
+	parts := strings.SplitN(clause, "==", 2)
+	if len(parts) != 2 {
+		parts = strings.SplitN(clause, "!=", 2)
+		op = "!="
+	}
+	if len(parts) != 2 {
+		return false, fmt.Errorf("rules: unsupported expression clause %q", clause)
+	}
+
+	field := strings.TrimSpace(parts[0])
+	literal := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	actual := fieldValue(e, field)
+
+	if op == "!=" {
+		return actual != literal, nil
+	}
+	return actual == literal, nil
+}
@@ -0,0 +1,281 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Event is the flattened view of a Kubernetes change that rule conditions
+// are evaluated against.
+type Event struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Reason    string
+	Message   string
+	// CorrelationID ties this event to the same notification across every
+	// system it fans out to - the Mattermost message, a rule's webhook
+	// payload, and DeployBot's own logs - so an operator can grep or
+	// search for one ID instead of reconstructing the trail from
+	// timestamps. Set by the controller when the event is built; empty on
+	// an Event constructed purely for rule testing (!test-rule).
+	CorrelationID string `json:"correlationId"`
+	// Severity is the highest Severity among the rules that matched this
+	// event, filled in by Engine.Classify. Empty until then, and left
+	// empty on events no configured rule matched.
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// Severity classifies how urgently a matched rule's event needs attention -
+// e.g. the same "delete" Reason is SeverityCritical against a prod
+// Namespace condition and SeverityInfo against a dev one. Consumed by the
+// controller to decide message formatting (an @here mention) and the
+// attachment's sidebar color (see mattermostapi.SeverityColor).
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// rank orders severities for Classify's "highest wins" comparison. Unknown
+// or unset values rank below SeverityInfo so a rule that doesn't set
+// Severity never outranks one that does.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Condition is a single flat match against a field of Event, e.g.
+// {Field: "Namespace", Op: "eq", Equals: "prod"}. Op defaults to "eq" when
+// empty; "regex" treats Equals as a regular expression matched against the
+// field value.
+type Condition struct {
+	Field  string
+	Op     string
+	Equals string
+}
+
+// ActionType identifies what a matched rule should do beyond the default
+// chat notification.
+type ActionType string
+
+const (
+	ActionWebhook ActionType = "webhook"
+	ActionTicket  ActionType = "ticket"
+	ActionRunbook ActionType = "runbook"
+)
+
+// Action describes one fan-out side effect a matched rule triggers in
+// addition to the usual Mattermost post.
+type Action struct {
+	Type   ActionType
+	Target string // webhook URL, ticket queue, or runbook name
+}
+
+// Rule is a named set of conditions (all must match) plus the actions to
+// run when they do. Expr, when set, is evaluated in addition to
+// Conditions - see expr.go for the supported syntax.
+type Rule struct {
+	Name       string
+	Conditions []Condition
+	Expr       string
+	Actions    []Action
+	// Shadow runs the rule's matching logic without dispatching its
+	// Actions - matches are only counted in DefaultShadowStats, visible
+	// via `!rule-stats`, so a new or modified rule can be evaluated
+	// against real traffic before it's trusted to act on it.
+	Shadow bool
+	// Severity is assigned by whoever authors the rule, not inferred from
+	// the event itself - the same Reason ("Deleted") is SeverityCritical
+	// under a rule scoped to prod and SeverityInfo under one scoped to
+	// dev. Left empty, the rule doesn't contribute to Classify's result.
+	Severity Severity
+}
+
+func fieldValue(e Event, field string) string {
+	switch field {
+	case "Namespace":
+		return e.Namespace
+	case "Kind":
+		return e.Kind
+	case "Name":
+		return e.Name
+	case "Reason":
+		return e.Reason
+	case "Message":
+		return e.Message
+	default:
+		return ""
+	}
+}
+
+// Matches reports whether every condition on the rule holds for e, and -
+// if Expr is set - that the expression also evaluates truthy.
+func (r Rule) Matches(e Event) bool {
+	for _, c := range r.Conditions {
+		if !c.matches(fieldValue(e, c.Field)) {
+			return false
+		}
+	}
+	if r.Expr != "" {
+		ok, err := evalExpr(r.Expr, e)
+		if err != nil {
+			return false
+		}
+		return ok
+	}
+	return true
+}
+
+// ConditionResult is the outcome of evaluating a single Condition, used by
+// Explain to show why a rule did or didn't match.
+type ConditionResult struct {
+	Condition Condition
+	Value     string
+	Matched   bool
+}
+
+// RuleResult is the full breakdown of evaluating a Rule against an Event,
+// suitable for rendering in a `!test-rule` response.
+type RuleResult struct {
+	Rule       Rule
+	Conditions []ConditionResult
+	ExprOK     *bool
+	Matched    bool
+}
+
+// Explain evaluates rule against e like Matches does, but returns the
+// per-condition breakdown instead of a single bool.
+func (r Rule) Explain(e Event) RuleResult {
+	result := RuleResult{Rule: r, Matched: true}
+	for _, c := range r.Conditions {
+		value := fieldValue(e, c.Field)
+		matched := c.matches(value)
+		result.Conditions = append(result.Conditions, ConditionResult{Condition: c, Value: value, Matched: matched})
+		if !matched {
+			result.Matched = false
+		}
+	}
+	if r.Expr != "" {
+		ok, err := evalExpr(r.Expr, e)
+		if err != nil {
+			ok = false
+		}
+		result.ExprOK = &ok
+		if !ok {
+			result.Matched = false
+		}
+	}
+	return result
+}
+
+func (c Condition) matches(value string) bool {
+	switch c.Op {
+	case "regex":
+		re, err := regexp.Compile(c.Equals)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(value)
+	case "neq":
+		return value != c.Equals
+	default:
+		return value == c.Equals
+	}
+}
+
+// Engine evaluates an event against a fixed set of rules.
+type Engine struct {
+	Rules []Rule
+}
+
+// Evaluate returns every non-shadow rule whose conditions match e. Shadow
+// rules that match are recorded in DefaultShadowStats instead of being
+// returned, so callers never dispatch their Actions.
+func (en *Engine) Evaluate(e Event) []Rule {
+	var matched []Rule
+	for _, r := range en.Rules {
+		if !r.Matches(e) {
+			continue
+		}
+		if r.Shadow {
+			DefaultShadowStats.record(r.Name)
+			continue
+		}
+		matched = append(matched, r)
+	}
+	return matched
+}
+
+// Classify returns the highest Severity among rules matching e - the same
+// rules Evaluate would return, plus shadow rules that matched (a shadow
+// rule's Severity is still informative even though its Actions never
+// dispatch). Returns "" if no matching rule set a Severity at all, so
+// callers can tell "nothing matched" apart from an explicit SeverityInfo.
+func (en *Engine) Classify(e Event) Severity {
+	var best Severity
+	for _, r := range en.Rules {
+		if r.Severity == "" || !r.Matches(e) {
+			continue
+		}
+		if r.Severity.rank() > best.rank() {
+			best = r.Severity
+		}
+	}
+	return best
+}
+
+// ShadowStats counts how many times each shadow-mode rule has matched,
+// without ever dispatching its actions.
+type ShadowStats struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// DefaultShadowStats is the process-wide shadow match counter, read by
+// `!rule-stats`.
+var DefaultShadowStats = NewShadowStats()
+
+// NewShadowStats builds an empty ShadowStats.
+func NewShadowStats() *ShadowStats {
+	return &ShadowStats{counts: make(map[string]int)}
+}
+
+func (s *ShadowStats) record(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[name]++
+}
+
+// Report renders every shadow rule's match count so far, sorted by name.
+func (s *ShadowStats) Report() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.counts) == 0 {
+		return "No shadow rule matches recorded yet."
+	}
+	names := make([]string, 0, len(s.counts))
+	for name := range s.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := "Shadow rule matches:\n"
+	for _, name := range names {
+		report += fmt.Sprintf("- %s: %d\n", name, s.counts[name])
+	}
+	return report
+}
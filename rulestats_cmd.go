@@ -0,0 +1,16 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"DeployBot/rules"
+	"strings"
+)
+
+// ruleStatsCommand implements `!rule-stats`, reporting how many times each
+// shadow-mode rule has matched without being dispatched.
+func ruleStatsCommand(message string) string {
+	if len(strings.Fields(message)) != 1 {
+		return guard.Ephemeral("Usage: !rule-stats")
+	}
+	return rules.DefaultShadowStats.Report()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"DeployBot/audit"
+	"DeployBot/guard"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sudoCodeWindow bounds how long a !sudo confirmation code stays valid -
+// long enough to read a DM and type it back, short enough that a code
+// leaked or guessed later is useless.
+const sudoCodeWindow = 2 * time.Minute
+
+// pendingElevation is one user's staged !sudo command, waiting on
+// sudoConfirmCommand to supply the matching Code before Command runs.
+type pendingElevation struct {
+	Command string
+	Code    string
+	Expires time.Time
+}
+
+var (
+	sudoMu      sync.Mutex
+	sudoPending = make(map[string]pendingElevation)
+)
+
+// sudoCommand implements `!sudo <command>`, putting one command behind a
+// second-factor confirmation. It DMs the caller a one-time code and stages
+// command to run - via dispatchCommand, exactly as if they'd typed it
+// themselves - once sudoConfirmCommand receives that code within
+// sudoCodeWindow. The staged command runs under the caller's own userId, so
+// its own authorized() check still applies: !sudo grants no rights the
+// caller doesn't already have. Every request, confirmation, denial, and
+// expiry is recorded to audit.Default.
+func sudoCommand(message, userId string) string {
+	command := strings.TrimSpace(strings.TrimPrefix(message, "!sudo"))
+	if command == "" {
+		return guard.Ephemeral("Usage: !sudo <command> (e.g. !sudo !netcheck prod db.internal)")
+	}
+	if !authorized(userId, "sudo", command) {
+		return guard.Ephemeral("DeployBot - !sudo is restricted to admins.")
+	}
+
+	code, err := randomSudoCode()
+	if err != nil {
+		return "DeployBot - couldn't generate a confirmation code, try again."
+	}
+
+	sudoMu.Lock()
+	sudoPending[userId] = pendingElevation{Command: command, Code: code, Expires: time.Now().Add(sudoCodeWindow)}
+	sudoMu.Unlock()
+
+	if err := audit.Default.Append(userId, "sudo-request", command, "confirmation code sent via DM"); err != nil {
+		fmt.Println("audit: failed to record sudo request:", err)
+	}
+
+	client := botMatterMost.GetClient()
+	dm := botMatterMost.SendDirectMessage(client, userId,
+		fmt.Sprintf("DeployBot - confirmation code for `%s`: **%s** (run !sudo-confirm %s within %s)", command, code, code, sudoCodeWindow))
+	if dm == nil {
+		return "DeployBot - couldn't DM you a confirmation code; check that DeployBot is able to message you directly."
+	}
+	return fmt.Sprintf("DeployBot - sent a confirmation code to your DMs. Run !sudo-confirm <code> within %s to execute: %s", sudoCodeWindow, command)
+}
+
+// sudoConfirmCommand implements `!sudo-confirm <code>`, running userId's
+// staged !sudo command exactly once if code matches and hasn't expired.
+// The pending elevation is consumed either way - a wrong or expired code
+// doesn't get a second guess against the same staged command.
+func sudoConfirmCommand(message, userId string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 {
+		return guard.Ephemeral("Usage: !sudo-confirm <code>")
+	}
+	code := fields[1]
+
+	sudoMu.Lock()
+	pending, ok := sudoPending[userId]
+	delete(sudoPending, userId)
+	sudoMu.Unlock()
+
+	if !ok {
+		return "DeployBot - no pending !sudo elevation for you; run !sudo <command> first."
+	}
+	if time.Now().After(pending.Expires) {
+		audit.Default.Append(userId, "sudo-expired", pending.Command, "")
+		return "DeployBot - that confirmation code expired; run !sudo <command> again."
+	}
+	if code != pending.Code {
+		audit.Default.Append(userId, "sudo-denied", pending.Command, "wrong code")
+		return "DeployBot - wrong code."
+	}
+
+	if err := audit.Default.Append(userId, "sudo-confirmed", pending.Command, ""); err != nil {
+		fmt.Println("audit: failed to record sudo confirmation:", err)
+	}
+
+	reply, handled := dispatchCommand(pending.Command, userId, "")
+	if !handled {
+		return "DeployBot - confirmed, but " + pending.Command + " isn't a recognized command."
+	}
+	return reply
+}
+
+// randomSudoCode returns a 6-digit numeric confirmation code.
+func randomSudoCode() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	n := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	return fmt.Sprintf("%06d", n%1000000), nil
+}
@@ -0,0 +1,30 @@
+package templates
+
+// DefaultTemplates are the built-in message templates for DeployBot's
+// event types, seeded into a Store (see Seed) before any config-sourced
+// Templates are synced on top - so an install that hasn't touched its
+// config Git repo/ConfigMap yet still gets sensible wording instead of
+// Render failing on an unknown name, and an override only has to name the
+// template it actually wants to change.
+var DefaultTemplates = []Template{
+	{Name: "deployment.created", Version: "built-in", Body: "DeployBot - Created a new Deployment in OCP - {{.Name}}"},
+	{Name: "deployment.updated", Version: "built-in", Body: "DeployBot - Deployment {{.Name}} updated - {{.Diff}}"},
+	{Name: "deployment.deleted", Version: "built-in", Body: "DeployBot - Deleted Deployment in OCP - {{.Key}}{{.Impact}}"},
+	{Name: "pod.crash", Version: "built-in", Body: "DeployBot - WARNING: {{.Kind}} {{.Namespace}}/{{.Name}} - {{.Reason}}: {{.Message}}"},
+	{Name: "node.alert", Version: "built-in", Body: "DeployBot - ALERT: node {{.Name}} is {{.Condition}}{{if .Info}} [{{.Info}}]{{end}}"},
+	{Name: "node.recovered", Version: "built-in", Body: "DeployBot - RECOVERED: node {{.Name}} is no longer {{.Condition}}{{if .Info}} [{{.Info}}]{{end}}"},
+}
+
+// Seed registers defaults into s for any name not already present, leaving
+// an already-configured template (from an earlier Sync, e.g. one restored
+// from the config file on disk) untouched - so restarting doesn't clobber
+// an operator's override back to the built-in wording.
+func (s *Store) Seed(defaults []Template) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range defaults {
+		if _, ok := s.current[t.Name]; !ok {
+			s.current[t.Name] = t
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// funcMap is made available to every template Render executes. Keeping
+// templates short and consistent means pushing formatting logic here
+// rather than letting each template reinvent it with text/template's bare
+// pipeline syntax.
+var funcMap = template.FuncMap{
+	"humanizeDuration": humanizeDuration,
+	"imageTag":         imageTag,
+	"imageRepo":        imageRepo,
+	"truncate":         truncate,
+	"severityEmoji":    severityEmoji,
+	"mdLink":           mdLink,
+	"snippet":          Default.Snippet,
+}
+
+// humanizeDuration renders seconds as a duration string such as "1h15m0s",
+// "45m0s", or "30s", for templates that only have a raw elapsed-seconds
+// number to work with.
+func humanizeDuration(seconds int64) string {
+	return time.Duration(seconds * int64(time.Second)).Round(time.Second).String()
+}
+
+// imageTag returns image's tag, or "latest" if it has none - e.g.
+// "registry.example.com/app:v1.2.3" -> "v1.2.3".
+func imageTag(image string) string {
+	ref := image
+	if i := strings.LastIndex(ref, "/"); i != -1 {
+		ref = ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i != -1 {
+		return ref[i+1:]
+	}
+	return "latest"
+}
+
+// imageRepo returns image with its tag (and digest, if present) stripped
+// - e.g. "registry.example.com/app:v1.2.3" -> "registry.example.com/app".
+func imageRepo(image string) string {
+	repo := image
+	if i := strings.Index(repo, "@"); i != -1 {
+		repo = repo[:i]
+	}
+	lastSlash := strings.LastIndex(repo, "/")
+	if i := strings.LastIndex(repo, ":"); i != -1 && i > lastSlash {
+		return repo[:i]
+	}
+	return repo
+}
+
+// truncate shortens s to at most n runes, appending "..." when it had to
+// cut anything, so a template can bound something like a pod's event
+// message without it blowing out the rest of the post.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(r[:n])
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// severityEmoji maps a rules.Severity string to the Mattermost emoji this
+// bot's other hand-written messages already use for that severity.
+func severityEmoji(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return ":rotating_light:"
+	case "warning":
+		return ":warning:"
+	case "info":
+		return ":information_source:"
+	default:
+		return ":question:"
+	}
+}
+
+// mdLink renders a Mattermost-flavored Markdown link.
+func mdLink(text, url string) string {
+	return fmt.Sprintf("[%s](%s)", text, url)
+}
@@ -0,0 +1,128 @@
+// Package templates renders versioned message templates and keeps a
+// bounded rollback history per template, so a bad template pulled from
+// Git can be reverted from chat without waiting for another sync.
+package templates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"text/template"
+)
+
+// maxHistory bounds how many prior versions of a single template are kept
+// for rollback.
+const maxHistory = 10
+
+// Template is one named message template, as pulled from the config Git
+// repo. Version is whatever the source tags it with (e.g. a date-based
+// release like "v2025.10.1") and is rendered in the message footer.
+type Template struct {
+	Name    string `json:"name"`
+	Body    string `json:"body"`
+	Version string `json:"version"`
+}
+
+// Store holds the current set of templates plus enough history per
+// template to roll back.
+type Store struct {
+	mu       sync.RWMutex
+	current  map[string]Template
+	history  map[string][]Template
+	snippets map[string]string
+}
+
+// Default is the process-wide template store, synced from config.
+var Default = NewStore()
+
+// NewStore builds an empty Store.
+func NewStore() *Store {
+	return &Store{current: make(map[string]Template), history: make(map[string][]Template), snippets: make(map[string]string)}
+}
+
+// SyncSnippets replaces the store's named static snippets, e.g. a
+// disclaimer or support-channel mention several templates pull in with
+// {{snippet "name"}} instead of repeating it.
+func (s *Store) SyncSnippets(snippets map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snippets = make(map[string]string, len(snippets))
+	for name, body := range snippets {
+		s.snippets[name] = body
+	}
+}
+
+// Snippet returns the named static snippet, or "" if none is registered -
+// a typo'd snippet name renders as empty text rather than failing the
+// whole template.
+func (s *Store) Snippet(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snippets[name]
+}
+
+// checksum returns a short content fingerprint for body. It's not a
+// cryptographic signature - no signing keys are configured in this tree -
+// but it lets operators confirm the rendered footer matches what's in
+// Git.
+func checksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Sync replaces the store's templates with ts, pushing the previous body
+// of any changed template onto its rollback history.
+func (s *Store) Sync(ts []Template) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range ts {
+		if existing, ok := s.current[t.Name]; ok && existing.Version != t.Version {
+			hist := append(s.history[t.Name], existing)
+			if len(hist) > maxHistory {
+				hist = hist[len(hist)-maxHistory:]
+			}
+			s.history[t.Name] = hist
+		}
+		s.current[t.Name] = t
+	}
+}
+
+// Render executes the named template against data and appends a footer
+// identifying its version and checksum, e.g. "tpl v2025.10.1 (a1b2c3d4e5f6)".
+func (s *Store) Render(name string, data interface{}) (string, error) {
+	s.mu.RLock()
+	t, ok := s.current[name]
+	s.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no template named %q", name)
+	}
+
+	tpl, err := template.New(name).Funcs(funcMap).Parse(t.Body)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&buf, "\ntpl %s (%s)", t.Version, checksum(t.Body))
+	return buf.String(), nil
+}
+
+// Rollback reverts name to its most recently superseded version, for the
+// `!templates rollback <name>` chat command.
+func (s *Store) Rollback(name string) (Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hist := s.history[name]
+	if len(hist) == 0 {
+		return Template{}, fmt.Errorf("no earlier version of %q to roll back to", name)
+	}
+	prev := hist[len(hist)-1]
+	s.history[name] = hist[:len(hist)-1]
+	s.current[name] = prev
+	return prev, nil
+}
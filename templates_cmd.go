@@ -0,0 +1,22 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"DeployBot/templates"
+	"strings"
+)
+
+// templatesCommand implements `!templates rollback <name>`, reverting a
+// message template to the version it had before its most recent sync.
+func templatesCommand(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 3 || fields[1] != "rollback" {
+		return guard.Ephemeral("Usage: !templates rollback <name>")
+	}
+
+	prev, err := templates.Default.Rollback(fields[2])
+	if err != nil {
+		return err.Error()
+	}
+	return "Rolled back template " + fields[2] + " to " + prev.Version
+}
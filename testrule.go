@@ -0,0 +1,68 @@
+package main
+
+import (
+	"DeployBot/config"
+	"DeployBot/guard"
+	"DeployBot/rules"
+	"fmt"
+	"strings"
+)
+
+// testRule implements the `!test-rule <rule-name> <ns>/<kind>/<name>` chat
+// command, letting operators debug rule conditions without waiting for a
+// real cluster event to fire them.
+func testRule(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 3 {
+		return guard.Ephemeral("Usage: !test-rule <rule-name> <ns>/<kind>/<name>")
+	}
+
+	ruleName := fields[1]
+	coords := strings.Split(fields[2], "/")
+	if len(coords) != 3 {
+		return "Expected <ns>/<kind>/<name>, got " + fields[2]
+	}
+	event := rules.Event{Namespace: coords[0], Kind: coords[1], Name: coords[2]}
+
+	cfg, err := config.Load("config.json")
+	if err != nil {
+		return "Couldn't load config: " + err.Error()
+	}
+
+	for _, r := range cfg.Snapshot().Rules {
+		if r.Name != ruleName {
+			continue
+		}
+		return explainRule(r, event)
+	}
+	return "No rule named " + ruleName
+}
+
+func explainRule(r rules.Rule, e rules.Event) string {
+	result := r.Explain(e)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rule %q against %s/%s/%s: ", r.Name, e.Namespace, e.Kind, e.Name)
+	for _, c := range result.Conditions {
+		status := "FAILED"
+		if c.Matched {
+			status = "matched"
+		}
+		fmt.Fprintf(&b, "\n- %s %s: value=%q %s", c.Condition.Field, c.Condition.Equals, c.Value, status)
+	}
+	if result.ExprOK != nil {
+		status := "FAILED"
+		if *result.ExprOK {
+			status = "matched"
+		}
+		fmt.Fprintf(&b, "\n- expr %q: %s", r.Expr, status)
+	}
+	if result.Matched {
+		b.WriteString("\n=> rule matches, actions would fire")
+		if r.Severity != "" {
+			fmt.Fprintf(&b, " (severity: %s)", r.Severity)
+		}
+	} else {
+		b.WriteString("\n=> rule does not match")
+	}
+	return b.String()
+}
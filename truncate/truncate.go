@@ -0,0 +1,68 @@
+// Package truncate centralizes per-frontend message size limits and a
+// smart-truncation strategy so long YAML diffs and log excerpts don't get
+// silently cut off mid-content. A frontend that can't attach files (or
+// whose limit is small enough that even the truncated head won't fit)
+// still gets the best effort: a head/tail excerpt noting how much was
+// dropped.
+package truncate
+
+import "fmt"
+
+// DefaultLimit leaves headroom under Mattermost's own
+// POST_MESSAGE_MAX_RUNES_V1 (4000) - the conservative per-post character
+// limit Mattermost keeps enforcing client-side even on servers whose DB
+// column would allow more.
+const DefaultLimit = 3900
+
+// headKeep and tailKeep govern how Smart splits its excerpt: most of what
+// makes a big diff or log excerpt useful is in its first and last lines,
+// not whatever got cut from the middle.
+const (
+	headKeep = 2000
+	tailKeep = 1000
+)
+
+var limits = map[string]int{"mattermost": DefaultLimit}
+
+// Limit returns the configured message size limit (in runes) for frontend,
+// or DefaultLimit if nothing's been set for it.
+func Limit(frontend string) int {
+	if n, ok := limits[frontend]; ok {
+		return n
+	}
+	return DefaultLimit
+}
+
+// SetLimit overrides the limit for frontend, e.g. a bridge into a chat
+// system with a smaller per-message cap than Mattermost's.
+func SetLimit(frontend string, n int) {
+	limits[frontend] = n
+}
+
+// Smart truncates message to fit within limit runes by keeping its head
+// and tail and dropping the middle, noting how many characters were
+// omitted. It reports whether truncation happened at all - callers use
+// that to decide whether the untruncated message is worth attaching as a
+// file.
+func Smart(message string, limit int) (string, bool) {
+	runes := []rune(message)
+	if limit <= 0 || len(runes) <= limit {
+		return message, false
+	}
+
+	head, tail := headKeep, tailKeep
+	if head+tail > limit {
+		head = limit * 2 / 3
+		tail = limit - head
+	}
+	if head > len(runes) {
+		head = len(runes)
+	}
+	if tail > len(runes)-head {
+		tail = len(runes) - head
+	}
+
+	omitted := len(runes) - head - tail
+	marker := fmt.Sprintf("\n...(%d characters omitted - full content attached)...\n", omitted)
+	return string(runes[:head]) + marker + string(runes[len(runes)-tail:]), true
+}
@@ -0,0 +1,118 @@
+// Package upgradewatch tracks a cluster-wide "!upgrade-watch on" window:
+// while active, controllers raise their sensitivity for node cordons, pod
+// evictions, and kubelet version skew (normally debounced or
+// ignored entirely) and this package accumulates counts for the final
+// report !upgrade-watch off posts.
+package upgradewatch
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report summarizes one completed upgrade-watch window.
+type Report struct {
+	Started   time.Time
+	Duration  time.Duration
+	Cordons   []string
+	Evictions []string
+	Skews     []string
+}
+
+// String renders r the way a final upgrade report is posted to the channel.
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DeployBot - upgrade-watch report (%s, started %s):\n", r.Duration.Round(time.Second), r.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- %d node cordon/uncordon event(s)\n", len(r.Cordons))
+	for _, c := range r.Cordons {
+		fmt.Fprintf(&b, "  - %s\n", c)
+	}
+	fmt.Fprintf(&b, "- %d pod eviction(s)\n", len(r.Evictions))
+	for _, e := range r.Evictions {
+		fmt.Fprintf(&b, "  - %s\n", e)
+	}
+	fmt.Fprintf(&b, "- %d version skew observation(s)\n", len(r.Skews))
+	for _, s := range r.Skews {
+		fmt.Fprintf(&b, "  - %s\n", s)
+	}
+	return b.String()
+}
+
+var (
+	mu      sync.Mutex
+	active  bool
+	started time.Time
+	report  Report
+)
+
+// Active reports whether an upgrade-watch window is currently running.
+func Active() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return active
+}
+
+// Start begins a new upgrade-watch window, discarding any prior unreported
+// counts.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+	active = true
+	started = time.Now()
+	report = Report{}
+}
+
+// Stop ends the current window and returns its Report. Calling Stop when no
+// window is active returns a zero-value Report with Duration 0.
+func Stop() Report {
+	mu.Lock()
+	defer mu.Unlock()
+	if !active {
+		return Report{}
+	}
+	active = false
+	report.Started = started
+	report.Duration = time.Since(started)
+	return report
+}
+
+// RecordCordon notes a node's schedulability change. No-op when no window
+// is active.
+func RecordCordon(nodeName string, cordoned bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !active {
+		return
+	}
+	state := "cordoned"
+	if !cordoned {
+		state = "uncordoned"
+	}
+	report.Cordons = append(report.Cordons, fmt.Sprintf("%s %s at %s", nodeName, state, time.Now().Format(time.Kitchen)))
+}
+
+// RecordEviction notes a pod terminating while its node was cordoned,
+// DeployBot's proxy for "likely caused by the upgrade drain" since the
+// eviction subresource doesn't otherwise leave a trace on the Pod object.
+// No-op when no window is active.
+func RecordEviction(namespace, name, nodeName string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !active {
+		return
+	}
+	report.Evictions = append(report.Evictions, fmt.Sprintf("%s/%s (node %s) at %s", namespace, name, nodeName, time.Now().Format(time.Kitchen)))
+}
+
+// RecordVersionSkew notes that nodeName's kubelet version differs from the
+// rest of the cluster. No-op when no window is active.
+func RecordVersionSkew(nodeName, version, majorityVersion string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !active {
+		return
+	}
+	report.Skews = append(report.Skews, fmt.Sprintf("%s running %s (cluster majority: %s)", nodeName, version, majorityVersion))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"DeployBot/guard"
+	"DeployBot/upgradewatch"
+	"strings"
+)
+
+// upgradeWatchCommand implements `!upgrade-watch on` and `!upgrade-watch
+// off`, restricted via authorized() since it changes notification behavior
+// for everyone in the channel, not just the caller. While on, controllers
+// report every node cordon/uncordon, pod eviction, and kubelet version
+// skew instead of debouncing them; switching off posts a summary report of
+// everything seen during the window.
+func upgradeWatchCommand(message, userId string) string {
+	fields := strings.Fields(message)
+	if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+		return guard.Ephemeral("Usage: !upgrade-watch on | !upgrade-watch off")
+	}
+	if !authorized(userId, "upgrade-watch", fields[1]) {
+		return guard.Ephemeral("DeployBot - !upgrade-watch is restricted to admins.")
+	}
+
+	if fields[1] == "on" {
+		if upgradewatch.Active() {
+			return "DeployBot - upgrade-watch is already on."
+		}
+		upgradewatch.Start()
+		return "DeployBot - upgrade-watch on: reporting every node cordon, pod eviction, and version skew until switched off."
+	}
+
+	report := upgradewatch.Stop()
+	if report.Duration == 0 {
+		return "DeployBot - upgrade-watch wasn't on."
+	}
+	return report.String()
+}
@@ -0,0 +1,153 @@
+// Package vcr is a minimal VCR-style HTTP recorder/player: the first run
+// against a cassette path that doesn't exist yet performs real requests
+// and records them; every run after that replays the recorded responses
+// in order instead of touching the network. It exists so packages that
+// talk to an HTTP API - mattermostapi in particular - can be exercised
+// deterministically in tests without a live server, the same way Ruby's
+// VCR or Python's vcrpy do for their ecosystems.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	RequestBody    string            `json:"requestBody,omitempty"`
+	StatusCode     int               `json:"statusCode"`
+	ResponseBody   string            `json:"responseBody"`
+	ResponseHeader map[string]string `json:"responseHeader,omitempty"`
+}
+
+// Cassette is a recorded sequence of Interactions, persisted as JSON.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// RoundTripper implements http.RoundTripper, recording every request made
+// through it when its cassette file doesn't exist yet, and replaying the
+// previously recorded responses - in the same order they were recorded,
+// matched against method and URL - once it does. Install it as an
+// *http.Client's Transport (or, for mattermostapi, assign the *http.Client
+// wrapping it to MatterMost.HTTPClient) to make an otherwise-live API
+// deterministic.
+type RoundTripper struct {
+	path      string
+	recording bool
+	cassette  *Cassette
+	next      int
+	real      http.RoundTripper
+}
+
+// New loads path's cassette and returns a RoundTripper in replay mode, or,
+// if path doesn't exist yet, a RoundTripper in record mode that will
+// write one there on Save. real is the transport used to make the actual
+// request while recording (http.DefaultTransport if nil); it's never used
+// in replay mode.
+func New(path string, real http.RoundTripper) (*RoundTripper, error) {
+	if real == nil {
+		real = http.DefaultTransport
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &RoundTripper{path: path, recording: true, cassette: &Cassette{}, real: real}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vcr: reading cassette %s: %v", path, err)
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("vcr: parsing cassette %s: %v", path, err)
+	}
+	return &RoundTripper{path: path, cassette: &cassette, real: real}, nil
+}
+
+// RoundTrip satisfies http.RoundTripper. In record mode it performs the
+// real request and appends the interaction to the cassette; in replay
+// mode it returns the next recorded interaction whose method and URL
+// match, erroring if none is left.
+func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.recording {
+		return r.recordRoundTrip(req)
+	}
+	return r.replayRoundTrip(req)
+}
+
+func (r *RoundTripper) recordRoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		reqBody, _ = ioutil.ReadAll(req.Body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	header := make(map[string]string, len(resp.Header))
+	for k := range resp.Header {
+		header[k] = resp.Header.Get(k)
+	}
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestBody:    string(reqBody),
+		StatusCode:     resp.StatusCode,
+		ResponseBody:   string(respBody),
+		ResponseHeader: header,
+	})
+	return resp, nil
+}
+
+func (r *RoundTripper) replayRoundTrip(req *http.Request) (*http.Response, error) {
+	for r.next < len(r.cassette.Interactions) {
+		interaction := r.cassette.Interactions[r.next]
+		r.next++
+		if interaction.Method != req.Method || interaction.URL != req.URL.String() {
+			continue
+		}
+
+		header := http.Header{}
+		for k, v := range interaction.ResponseHeader {
+			header.Set(k, v)
+		}
+		return &http.Response{
+			StatusCode: interaction.StatusCode,
+			Status:     http.StatusText(interaction.StatusCode),
+			Header:     header,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(interaction.ResponseBody))),
+			Request:    req,
+		}, nil
+	}
+	return nil, fmt.Errorf("vcr: no recorded interaction left matching %s %s", req.Method, req.URL.String())
+}
+
+// Save persists the cassette to its path. A no-op in replay mode, since
+// nothing changed. Callers recording a new cassette should defer this so
+// a cassette is written even if the test that's populating it fails
+// partway through.
+func (r *RoundTripper) Save() error {
+	if !r.recording {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vcr: encoding cassette: %v", err)
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
@@ -0,0 +1,80 @@
+// Package verbose tracks short-lived "!verbose on" sessions: a thread that
+// asked to temporarily see fine-grained Pod transition events for a
+// namespace/pod-name-prefix it's already discussing, without raising the
+// global notification noise level for everyone else.
+package verbose
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDuration is how long a session stays active when the caller
+// doesn't ask for a different window.
+const DefaultDuration = 15 * time.Minute
+
+// session is a single thread's active verbose stream, identified by its
+// RootId alone. The thread's channel isn't recorded;
+// controller.streamPodTransition posts every reply through the
+// "DevopsBot" channel.
+type session struct {
+	RootId    string
+	Namespace string
+	PodPrefix string
+	Expires   time.Time
+}
+
+var (
+	mu       sync.Mutex
+	sessions []session
+)
+
+// Enable starts (or restarts) streaming Pod transitions under namespace
+// whose name has podPrefix into rootId's thread for d.
+func Enable(rootId, namespace, podPrefix string, d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	sessions = prune(sessions)
+	sessions = append(sessions, session{RootId: rootId, Namespace: namespace, PodPrefix: podPrefix, Expires: time.Now().Add(d)})
+}
+
+// Disable stops every verbose session running in rootId's thread.
+func Disable(rootId string) {
+	mu.Lock()
+	defer mu.Unlock()
+	out := sessions[:0]
+	for _, s := range sessions {
+		if s.RootId != rootId {
+			out = append(out, s)
+		}
+	}
+	sessions = out
+}
+
+// Threads returns the RootId of every active session matching a Pod called
+// podName in namespace, so the caller can fan a single transition event out
+// to each thread currently watching it.
+func Threads(namespace, podName string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	sessions = prune(sessions)
+	var out []string
+	for _, s := range sessions {
+		if s.Namespace == namespace && strings.HasPrefix(podName, s.PodPrefix) {
+			out = append(out, s.RootId)
+		}
+	}
+	return out
+}
+
+func prune(in []session) []session {
+	now := time.Now()
+	out := in[:0]
+	for _, s := range in {
+		if s.Expires.After(now) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"DeployBot/verbose"
+	"fmt"
+	"strings"
+)
+
+// verboseCommand implements `!verbose on <namespace> <pod-name-prefix>` and
+// `!verbose off`, meant to be run as a reply inside an alert thread. While
+// active it streams every matching Pod's phase transitions into that thread
+// for verbose.DefaultDuration, without touching the global notification
+// filters everyone else sees.
+func verboseCommand(message, rootId string) string {
+	if rootId == "" {
+		return "DeployBot - !verbose only works inside a thread; reply to a message first."
+	}
+
+	fields := strings.Fields(message)
+	if len(fields) == 2 && fields[1] == "off" {
+		verbose.Disable(rootId)
+		return "DeployBot - verbose mode off for this thread."
+	}
+	if len(fields) != 4 || fields[1] != "on" {
+		return "Usage (as a reply in a thread): !verbose on <namespace> <pod-name-prefix> | !verbose off"
+	}
+
+	namespace, prefix := fields[2], fields[3]
+	verbose.Enable(rootId, namespace, prefix, verbose.DefaultDuration)
+	return fmt.Sprintf("DeployBot - streaming Pod transitions for %s/%s* into this thread for %s.", namespace, prefix, verbose.DefaultDuration)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"DeployBot/controller"
+	"strings"
+)
+
+// whatsWrongCommand implements `!whats-wrong [namespace]`, a zero-setup
+// triage entry point: it scans what the controllers already have cached -
+// no extra API calls - for unavailable replicas, stuck Pods, failing
+// probes, and crash-looping containers, and returns them as a prioritized
+// summary.
+func whatsWrongCommand(message string) string {
+	namespace := strings.TrimSpace(strings.TrimPrefix(message, "!whats-wrong"))
+	return controller.WhatsWrong(namespace)
+}